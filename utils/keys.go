@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// KeyLookup finds the private key to use for an Ethereum address
+type KeyLookup interface {
+	Get(address common.Address) (*ecdsa.PrivateKey, error)
+}
+
+type singleKey struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+func (lookup *singleKey) Get(address common.Address) (*ecdsa.PrivateKey, error) {
+	if address != lookup.address {
+		return nil, fmt.Errorf("no key for address %v", address.Hex())
+	}
+	return lookup.key, nil
+}
+
+// SingleKey returns a KeyLookup that only has a key for the key's own address
+func SingleKey(key *ecdsa.PrivateKey) KeyLookup {
+	return &singleKey{key, crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// KeyOptions holds the flags shared by commands that load private keys.
+// Keys may either be plaintext hex private keys, or encrypted JSON keystore
+// files as created by geth. The passphrase for encrypted keys is read from
+// --password-file, or the environment variable named by --password-env, or
+// else prompted for on the terminal.
+type KeyOptions struct {
+	passwordFile string
+	passwordEnv  string
+	passphrase   *string
+	mutex        sync.Mutex
+}
+
+func (opts *KeyOptions) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&opts.passwordFile, "password-file", "", "File containing the passphrase for encrypted key files")
+	f.StringVar(&opts.passwordEnv, "password-env", "", "Environment variable containing the passphrase for encrypted key files")
+}
+
+// Passphrase returns the passphrase for encrypted keys, prompting for it at
+// most once.
+func (opts *KeyOptions) Passphrase() (string, error) {
+	opts.mutex.Lock()
+	defer opts.mutex.Unlock()
+	if opts.passphrase != nil {
+		return *opts.passphrase, nil
+	}
+	var passphrase string
+	if opts.passwordFile != "" {
+		data, err := ioutil.ReadFile(opts.passwordFile)
+		if err != nil {
+			return "", err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	} else if opts.passwordEnv != "" {
+		value, ok := os.LookupEnv(opts.passwordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not set", opts.passwordEnv)
+		}
+		passphrase = value
+	} else {
+		var err error
+		passphrase, err = promptPassphrase("Key passphrase: ")
+		if err != nil {
+			return "", err
+		}
+	}
+	opts.passphrase = &passphrase
+	return passphrase, nil
+}
+
+// promptPassphrase reads a passphrase from the terminal, which is used
+// directly because stdin is usually carrying records.
+func promptPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.New("no terminal available to prompt for a passphrase. Use --password-file or --password-env")
+	}
+	defer tty.Close()
+	tty.WriteString(prompt)
+	setEcho(tty, false)
+	defer setEcho(tty, true)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	tty.WriteString("\n")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func setEcho(tty *os.File, on bool) {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	cmd.Run()
+}
+
+// isKeystoreJSON reports whether a key file's contents are a JSON keystore
+// rather than a plaintext hex private key.
+func isKeystoreJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// LoadKey loads a single private key from a plaintext or encrypted key file.
+func (opts *KeyOptions) LoadKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isKeystoreJSON(data) {
+		return crypto.LoadECDSA(path)
+	}
+	passphrase, err := opts.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %v: %v", path, err.Error())
+	}
+	return key.PrivateKey, nil
+}
+
+// Load returns a KeyLookup for path. If path is a directory, it is treated as
+// a keystore directory, and keys are chosen by address. Otherwise path is
+// loaded as a single key.
+func (opts *KeyOptions) Load(path string) (KeyLookup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		ring := NewKeyRing(opts)
+		if err := ring.AddDirectory(path); err != nil {
+			return nil, err
+		}
+		return ring, nil
+	}
+	key, err := opts.LoadKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return SingleKey(key), nil
+}
+
+// KeyRing is a KeyLookup holding keys for several addresses. Encrypted keys
+// are indexed by the address in their keystore file, and only decrypted the
+// first time they are needed.
+type KeyRing struct {
+	opts  *KeyOptions
+	files map[common.Address]string
+	keys  map[common.Address]*ecdsa.PrivateKey
+	mutex sync.Mutex
+}
+
+func NewKeyRing(opts *KeyOptions) *KeyRing {
+	return &KeyRing{
+		opts:  opts,
+		files: make(map[common.Address]string),
+		keys:  make(map[common.Address]*ecdsa.PrivateKey),
+	}
+}
+
+// AddFile indexes a plaintext or encrypted key file, returning its address
+func (ring *KeyRing) AddFile(path string) (common.Address, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	if !isKeystoreJSON(data) {
+		key, err := crypto.LoadECDSA(path)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("loading %v: %v", path, err.Error())
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		ring.keys[address] = key
+		return address, nil
+	}
+	keyJSON := struct {
+		Address string `json:"address"`
+	}{}
+	if err := json.Unmarshal(data, &keyJSON); err != nil {
+		return common.Address{}, fmt.Errorf("parsing %v: %v", path, err.Error())
+	}
+	if !common.IsHexAddress(keyJSON.Address) {
+		return common.Address{}, fmt.Errorf("%v has no valid address", path)
+	}
+	address := common.HexToAddress(keyJSON.Address)
+	ring.files[address] = path
+	return address, nil
+}
+
+// AddDirectory indexes every key file in a keystore directory. Hidden files
+// and subdirectories are skipped.
+func (ring *KeyRing) AddDirectory(path string) error {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, err := ring.AddFile(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ring *KeyRing) Get(address common.Address) (*ecdsa.PrivateKey, error) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	if key, ok := ring.keys[address]; ok {
+		return key, nil
+	}
+	path, ok := ring.files[address]
+	if !ok {
+		return nil, fmt.Errorf("no key for address %v", address.Hex())
+	}
+	key, err := ring.opts.LoadKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != address {
+		return nil, fmt.Errorf("key in %v does not match address %v", path, address.Hex())
+	}
+	ring.keys[address] = key
+	return key, nil
+}
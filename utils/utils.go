@@ -28,7 +28,7 @@ func SetIO(cmd IOCommand) error {
 		}
 	}
 	if outputFileName != "" {
-		outputFile, err = os.OpenFile(outputFileName, os.O_RDWR|os.O_CREATE, 644)
+		outputFile, err = OpenOutput(outputFileName)
 		if err != nil {
 			return err
 		}
@@ -37,6 +37,12 @@ func SetIO(cmd IOCommand) error {
 	return nil
 }
 
+// OpenOutput opens a file for writing records, for commands that have output
+// streams beyond the ones provided by SetIO. Any existing file is replaced.
+func OpenOutput(fileName string) (*os.File, error) {
+	return os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
 func WriteRecord(item interface{}, outFile io.Writer) error {
 	data, err := json.Marshal(item)
 	if err != nil {
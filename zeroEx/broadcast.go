@@ -0,0 +1,190 @@
+package zeroEx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// targetList collects the values of a flag that may be repeated
+type targetList []string
+
+func (targets *targetList) String() string {
+	return strings.Join(*targets, ",")
+}
+
+func (targets *targetList) Set(value string) error {
+	*targets = append(*targets, value)
+	return nil
+}
+
+type broadcast struct {
+	targets        targetList
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	makerShare     float64
+	retries        int
+	timeout        time.Duration
+	keyOpts        utils.KeyOptions
+}
+
+func (p *broadcast) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *broadcast) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*broadcast) Name() string     { return "broadcast" }
+func (*broadcast) Synopsis() string { return "Upload orders to several relayers" }
+func (*broadcast) Usage() string {
+	return `msv 0x broadcast --target RELAYER_URL [--target RELAYER_URL...] [--maker-share N] [--retries N] [--timeout DURATION] [--password-file FILE | --password-env VAR] [--input FILE] [--output FILE] KEY_FILE...:
+  Upload each order to every target relayer. Because each relayer sets its
+  own fees and fee recipient, every relayer gets its own copy of the order,
+  with fees from that relayer as with "msv 0x getFees", a new random salt,
+  and a new signature from the maker's key.
+
+  KEY_FILE may be one or more key files, or a keystore directory. A result
+  record is written for each copy, with the relayer it was sent to, its hash,
+  the HTTP status, and an error if it was not accepted.
+`
+}
+
+func (p *broadcast) SetFlags(f *flag.FlagSet) {
+	f.Var(&p.targets, "target", "A 0x relayer to upload to. May be repeated")
+	f.Float64Var(&p.makerShare, "maker-share", -1, "What share of fees")
+	f.IntVar(&p.retries, "retries", 3, "The number of times to retry an upload that failed with a network error, 5xx or 429 status")
+	f.DurationVar(&p.timeout, "timeout", 30*time.Second, "The timeout for each fee request and upload")
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	p.keyOpts.SetFlags(f)
+}
+
+func (p *broadcast) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() == 0 || len(p.targets) == 0 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var keys utils.KeyLookup
+	if f.NArg() == 1 {
+		var err error
+		if keys, err = p.keyOpts.Load(f.Arg(0)); err != nil {
+			log.Printf("Error loading key: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	} else {
+		ring := utils.NewKeyRing(&p.keyOpts)
+		for _, path := range f.Args() {
+			if _, err := ring.AddFile(path); err != nil {
+				log.Printf("Error loading key %v: %v", path, err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+		keys = ring
+	}
+	uploaders := make([]*Uploader, len(p.targets))
+	for i, target := range p.targets {
+		uploaders[i] = NewUploader(target, p.timeout, p.retries, 0)
+	}
+	return BroadcastMain(p.inputFile, p.outputFile, keys, uploaders, p.makerShare)
+}
+
+// cloneOrder returns a deep copy of order
+func cloneOrder(order *types.Order) (*types.Order, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	clone := &types.Order{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// relayerOrder prepares a copy of order for the uploader's relayer, with a
+// new salt, the relayer's fees and a new signature. Fee requests use the
+// uploader's client, so they have the same timeout as uploads.
+func relayerOrder(order *types.Order, uploader *Uploader, keys utils.KeyLookup, makerShare float64) (*types.Order, error) {
+	clone, err := cloneOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	// Salt before requesting fees, so the relayer quotes the order it will
+	// receive
+	rand.Read(clone.Salt[:])
+	fees, err := requestFees(uploader.Client, uploader.TargetURL, clone)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFees(clone, fees, makerShare); err != nil {
+		return nil, err
+	}
+	key, err := keys.Get(orCommon.ToGethAddress(clone.Maker))
+	if err != nil {
+		return nil, err
+	}
+	signOrderWithKey(clone, key)
+	return clone, nil
+}
+
+// BroadcastMain uploads a copy of each order to each relayer, with that
+// relayer's fees
+func BroadcastMain(inputFile io.Reader, outputFile io.Writer, keys utils.KeyLookup, uploaders []*Uploader, makerShare float64) subcommands.ExitStatus {
+	uploaded := make([]int, len(uploaders))
+	failed := 0
+	for order := range orderScanner(inputFile) {
+		for i, uploader := range uploaders {
+			clone, err := relayerOrder(order, uploader, keys, makerShare)
+			var annotations map[string]interface{}
+			if err != nil {
+				clone = order
+				annotations = map[string]interface{}{
+					"hash":   fmt.Sprintf("%#x", order.Hash()),
+					"status": 0,
+					"error":  err.Error(),
+				}
+			} else {
+				result := uploader.Upload(clone)
+				annotations = result.annotations(clone)
+				if result.ok() {
+					uploaded[i]++
+				} else {
+					err = fmt.Errorf("status %v: %v", result.status, result.err)
+				}
+			}
+			if err != nil {
+				failed++
+				log.Printf("Error broadcasting order %#x to %v: %v", order.Hash(), uploader.TargetURL, err.Error())
+			}
+			annotations["relayer"] = uploader.TargetURL
+			if err := writeAnnotatedOrder(clone, annotations, outputFile); err != nil {
+				log.Printf("Error writing result: %v", err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+	}
+	for i, uploader := range uploaders {
+		log.Printf("Uploaded %v orders to %v", uploaded[i], uploader.TargetURL)
+	}
+	if failed > 0 {
+		log.Printf("Failed to broadcast %v orders", failed)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
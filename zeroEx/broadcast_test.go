@@ -0,0 +1,103 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	feeRecipients := []string{"0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002"}
+	relayers := []*testRelayer{}
+	uploaders := []*zeroEx.Uploader{}
+	for _, feeRecipient := range feeRecipients {
+		relayer := newTestRelayer(t, feeRecipient)
+		defer relayer.Close()
+		relayers = append(relayers, relayer)
+		uploaders = append(uploaders, zeroEx.NewUploader(relayer.URL, time.Second, 0, 0))
+	}
+	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	order := &types.Order{}
+	order.Initialize()
+	copy(order.Maker[:], address[:])
+	orderBytes, _ := json.Marshal(order)
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.BroadcastMain(bytes.NewReader(orderBytes), outputBuffer, utils.SingleKey(key), uploaders, 0.5); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	salts := make(map[types.Uint256]bool)
+	uploaded := make([][]*types.Order, len(relayers))
+	for i, feeRecipient := range feeRecipients {
+		uploaded[i] = relayers[i].Uploaded()
+		if len(uploaded[i]) != 1 {
+			t.Fatalf("Expected 1 order uploaded to relayer %v, got %v", i, len(uploaded[i]))
+		}
+		relayerOrder := uploaded[i][0]
+		if relayerOrder.FeeRecipient.String() != feeRecipient {
+			t.Errorf("Relayer %v got fee recipient %v", i, relayerOrder.FeeRecipient)
+		}
+		if relayerOrder.MakerFee[31] != 150 || relayerOrder.TakerFee[31] != 150 {
+			t.Errorf("Relayer %v got unexpected fees %v and %v", i, relayerOrder.MakerFee[31], relayerOrder.TakerFee[31])
+		}
+		if !relayerOrder.Signature.Verify(relayerOrder.Maker) {
+			t.Errorf("Relayer %v got an order with an invalid signature", i)
+		}
+		salts[*relayerOrder.Salt] = true
+		requests := relayers[i].Requests()
+		if len(requests) != 1 || requests[0]["salt"] != new(big.Int).SetBytes(relayerOrder.Salt[:]).String() {
+			t.Errorf("Relayer %v quoted fees for %v, not the uploaded order's salt", i, requests)
+		}
+	}
+	if len(salts) != 2 {
+		t.Errorf("Expected each relayer's order to have a different salt")
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		record := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err.Error())
+		}
+		if record["relayer"] != uploaders[counter].TargetURL {
+			t.Errorf("Record %v has relayer %v", counter, record["relayer"])
+		}
+		if record["hash"] != fmt.Sprintf("%#x", uploaded[counter][0].Hash()) {
+			t.Errorf("Record %v has hash %v", counter, record["hash"])
+		}
+		counter++
+	}
+	if counter != 2 {
+		t.Errorf("Expected 2 records, got %v", counter)
+	}
+}
+
+func TestBroadcastFeeTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	order := &types.Order{}
+	order.Initialize()
+	orderBytes, _ := json.Marshal(order)
+	uploaders := []*zeroEx.Uploader{zeroEx.NewUploader(server.URL, 50*time.Millisecond, 0, 0)}
+	if status := zeroEx.BroadcastMain(bytes.NewReader(orderBytes), &bytes.Buffer{}, utils.SingleKey(key), uploaders, -1); status != subcommands.ExitFailure {
+		t.Errorf("Expected a fee request timeout to fail, got %v", status)
+	}
+}
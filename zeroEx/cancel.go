@@ -0,0 +1,214 @@
+package zeroEx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+)
+
+type cancel struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	amount         string
+	batchSize      int
+	keyOpts        utils.KeyOptions
+	txOpts         transactionOptions
+}
+
+func (p *cancel) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *cancel) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*cancel) Name() string     { return "cancel" }
+func (*cancel) Synopsis() string { return "Cancel orders on-chain" }
+func (*cancel) Usage() string {
+	return `msv 0x cancel [--amount AMOUNT] [--batch-size N] [--dry-run] [--password-file FILE | --password-env VAR] [--gas-price WEI | --gas-price-strategy STRATEGY] [--gas-limit GAS] [--max-gas-price WEI] [--chain-id ID] [--input FILE] [--output FILE] ETHEREUM_RPC_URL KEY_FILE:
+  Cancel each order on its exchange contract using the key belonging to the
+  order's maker. KEY_FILE may be a plaintext private key, an encrypted geth
+  keystore file, or a keystore directory holding keys for several makers.
+  Orders are grouped by maker and exchange contract and cancelled with
+  batchCancelOrders, up to --batch-size orders per transaction. If --amount
+  is provided, only AMOUNT of each order's taker token amount will be
+  cancelled.
+
+  Each order is written to the output along with the cancellation
+  transaction hash and the total taker token amount cancelled on-chain. With
+  --dry-run, the cancellations are estimated but not sent, and each record
+  instead contains the amount that would be cancelled and the batch's
+  calldata, estimated gas and cost.
+`
+}
+
+func (p *cancel) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.amount, "amount", "", "The taker token amount to cancel on each order, in base units")
+	f.IntVar(&p.batchSize, "batch-size", 20, "The maximum number of orders to cancel in a single transaction")
+	p.keyOpts.SetFlags(f)
+	p.txOpts.SetFlags(f)
+}
+
+func (p *cancel) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var amount *big.Int
+	if p.amount != "" {
+		var ok bool
+		amount, ok = new(big.Int).SetString(p.amount, 10)
+		if !ok {
+			log.Printf("Error processing amount: %v", p.amount)
+			return subcommands.ExitFailure
+		}
+	}
+	client, err := rpc.Dial(f.Arg(0))
+	if err != nil {
+		log.Printf("Error establishing Ethereum connection: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	conn := ethclient.NewClient(client)
+	keys, err := p.keyOpts.Load(f.Arg(1))
+	if err != nil {
+		log.Printf("Error loading key: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	sender, err := p.txOpts.Sender(client, conn)
+	if err != nil {
+		log.Printf("Error configuring transactions: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return CancelMain(p.inputFile, p.outputFile, conn, sender, keys, amount, p.batchSize)
+}
+
+// cancelAmount returns the taker token amount to cancel for an order
+func cancelAmount(order *types.Order, amount *big.Int) *big.Int {
+	takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:])
+	if amount == nil || amount.Cmp(takerTokenAmount) > 0 {
+		return takerTokenAmount
+	}
+	return amount
+}
+
+// sendCancel cancels a batch of orders that share an exchange contract,
+// returning the cancellation transaction.
+func sendCancel(sender *TransactionSender, key *ecdsa.PrivateKey, exchange *exchangecontract.Exchange, orders []*types.Order, amount *big.Int) (*gethTypes.Transaction, error) {
+	transaction, _, err := sender.TransactAndWait(key, func(opts *bind.TransactOpts) (*gethTypes.Transaction, error) {
+		if len(orders) == 1 {
+			return exchange.CancelOrder(opts, orderAddresses(orders[0]), orderValues(orders[0]), cancelAmount(orders[0], amount))
+		}
+		addresses, values, _, _, _ := batchOrderArgs(orders)
+		amounts := make([]*big.Int, len(orders))
+		for i, order := range orders {
+			amounts[i] = cancelAmount(order, amount)
+		}
+		return rawExchangeTransact(exchange, opts, "batchCancelOrders", addresses, values, amounts)
+	})
+	return transaction, err
+}
+
+// cancelGroup identifies orders that can be cancelled in the same
+// transaction
+type cancelGroup struct {
+	maker    types.Address
+	exchange types.Address
+}
+
+func CancelMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, sender *TransactionSender, keys utils.KeyLookup, amount *big.Int, batchSize int) subcommands.ExitStatus {
+	if batchSize < 1 {
+		log.Printf("Batch size must be at least 1")
+		return subcommands.ExitFailure
+	}
+	groupOrders := make(map[cancelGroup][]*types.Order)
+	groups := []cancelGroup{}
+	groupKeys := make(map[cancelGroup]*ecdsa.PrivateKey)
+	failed := 0
+	for order := range orderScanner(inputFile) {
+		key, err := keys.Get(orCommon.ToGethAddress(order.Maker))
+		if err != nil {
+			failed++
+			log.Printf("Error getting key for order %#x: %v", order.Hash(), err.Error())
+			writeAnnotatedOrder(order, map[string]interface{}{"error": err.Error()}, outputFile)
+			continue
+		}
+		group := cancelGroup{*order.Maker, *order.ExchangeAddress}
+		if _, ok := groupOrders[group]; !ok {
+			groups = append(groups, group)
+			groupKeys[group] = key
+		}
+		groupOrders[group] = append(groupOrders[group], order)
+	}
+	for _, group := range groups {
+		exchangeAddress := group.exchange
+		key := groupKeys[group]
+		exchange, err := exchangecontract.NewExchange(orCommon.BytesToAddress(exchangeAddress), conn)
+		if err != nil {
+			log.Printf("Error initializing exchange contract %#x: %v", exchangeAddress[:], err.Error())
+			return subcommands.ExitFailure
+		}
+		orders := groupOrders[group]
+		for start := 0; start < len(orders); start += batchSize {
+			end := start + batchSize
+			if end > len(orders) {
+				end = len(orders)
+			}
+			batch := orders[start:end]
+			transaction, err := sendCancel(sender, key, exchange, batch, amount)
+			for _, order := range batch {
+				annotations := map[string]interface{}{}
+				if err == nil && sender.DryRun {
+					annotations = dryRunAnnotations(transaction)
+					annotations["cancelTakerTokenAmount"] = cancelAmount(order, amount).String()
+				} else {
+					annotations["txHash"] = ""
+					if transaction != nil {
+						annotations["txHash"] = transaction.Hash().Hex()
+					}
+					if err != nil {
+						annotations["error"] = err.Error()
+					}
+					cancelled, cancelErr := exchange.Cancelled(nil, orderHash(order))
+					if cancelErr != nil {
+						annotations["error"] = cancelErr.Error()
+					} else {
+						annotations["cancelledTakerTokenAmount"] = cancelled.String()
+					}
+				}
+				if _, ok := annotations["error"]; ok {
+					failed++
+					log.Printf("Error cancelling order %#x: %v", order.Hash(), annotations["error"])
+				}
+				if err := writeAnnotatedOrder(order, annotations, outputFile); err != nil {
+					log.Printf("Error writing result: %v", err.Error())
+					return subcommands.ExitFailure
+				}
+			}
+		}
+	}
+	sender.LogDryRunCost()
+	if failed > 0 {
+		log.Printf("Failed to cancel %v orders", failed)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
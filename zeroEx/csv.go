@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"encoding/hex"
 	"flag"
+	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/utils"
@@ -14,6 +15,7 @@ import (
 	"math/big"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type csvReader struct {
@@ -21,6 +23,10 @@ type csvReader struct {
 	outputFileName string
 	inputFile      *os.File
 	outputFile     *os.File
+	tokenFiles     string
+	rpcURL         string
+	decimalAmounts bool
+	priceTolerance string
 }
 
 func (p *csvReader) FileNames() (string, string) {
@@ -34,14 +40,38 @@ func (p *csvReader) SetIOFiles(inputFile, outputFile *os.File) {
 func (*csvReader) Name() string     { return "csv" }
 func (*csvReader) Synopsis() string { return "Parse orders out of a CSV" }
 func (*csvReader) Usage() string {
-	return `msv 0x csv [--input FILE] [--output FILE]:
+	return `msv 0x csv [--tokens FILE[,FILE...]] [--rpc ETHEREUM_RPC_URL] [--decimal-amounts] [--price-tolerance FRACTION] [--input FILE] [--output FILE]:
   Parse orders out of a CSV and add them to a stream
+
+  The makerTokenAddress and takerTokenAddress columns may hold token symbols
+  such as "ZRX" instead of addresses, which are resolved using the token
+  registry files given by --tokens. A registry file is a JSON list of objects
+  with "symbol", "address" and "decimals" fields. If --rpc is provided, any
+  missing symbols and decimals are looked up on-chain.
+
+  When a token is given by symbol, or when --decimal-amounts is set, that
+  token's amount column is read as a decimal number of tokens, such as "1.5",
+  and scaled by the token's decimals. Amounts with more decimal places than
+  the token allows are rejected. Fees are always read in base units.
+
+  Instead of token and amount columns, orders may be given by side, baseToken,
+  quoteToken, price and size columns. A "sell" order offers size of the base
+  token for price quote tokens each, and a "buy" order bids for size of the
+  base token at price quote tokens each. The base and quote tokens may be
+  symbols or addresses, but must be in the token registry or available
+  on-chain. The quote token amount is rounded in the maker's favor, and a
+  warning is logged if rounding moves the price by more than the fraction
+  given by --price-tolerance.
 `
 }
 
 func (p *csvReader) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
 	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.tokenFiles, "tokens", "", "Comma separated token registry files")
+	f.StringVar(&p.rpcURL, "rpc", "", "Ethereum RPC URL for looking up token symbols and decimals")
+	f.BoolVar(&p.decimalAmounts, "decimal-amounts", false, "Read all token amounts as decimal numbers of tokens")
+	f.StringVar(&p.priceTolerance, "price-tolerance", "0.0001", "Warn when rounding moves a price based order's price by more than this fraction")
 }
 
 func (p *csvReader) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -49,10 +79,83 @@ func (p *csvReader) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		os.Stderr.WriteString(p.Usage())
 	}
 	utils.SetIO(p)
-	return CSVMain(p.inputFile, p.outputFile)
+	tokens, err := loadTokenRegistry(p.rpcURL, p.tokenFiles)
+	if err != nil {
+		log.Printf("Error loading token registry: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	priceTolerance, ok := new(big.Rat).SetString(p.priceTolerance)
+	if !ok {
+		log.Printf("Invalid price tolerance: %v", p.priceTolerance)
+		return subcommands.ExitUsageError
+	}
+	return CSVTokensMain(p.inputFile, p.outputFile, tokens, p.decimalAmounts, priceTolerance)
+}
+
+// tokenAmount parses an amount of token. If token is nil the amount is in
+// base units, otherwise it is a decimal amount of the token.
+func tokenAmount(value string, token *Token) (*big.Int, error) {
+	if token == nil {
+		amount, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount: %v", value)
+		}
+		return amount, nil
+	}
+	return token.BaseUnits(value)
+}
+
+// csvToken parses a token column, which may hold either an address or a
+// symbol. The token is returned if the column's amounts should be read as
+// decimals.
+func csvToken(value string, tokens *TokenRegistry, decimalAmounts bool) ([]byte, *Token, error) {
+	if value == "" {
+		return []byte{}, nil, nil
+	}
+	if strings.HasPrefix(value, "0x") {
+		addressBytes, err := hex.DecodeString(value[2:])
+		if err != nil || !decimalAmounts {
+			return addressBytes, nil, err
+		}
+		token, err := tokens.Resolve(value)
+		return addressBytes, token, err
+	}
+	token, err := tokens.Symbol(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token.address[:], token, nil
+}
+
+// csvPriceOrder computes a record's token amounts from its side, baseToken,
+// quoteToken, price and size columns
+func csvPriceOrder(record []string, headerMap map[string]int, tokens *TokenRegistry) (*priceAmounts, error) {
+	values := make(map[string]string)
+	for _, column := range []string{"side", "baseToken", "quoteToken", "price", "size"} {
+		idx, ok := headerMap[column]
+		if !ok || record[idx] == "" {
+			return nil, fmt.Errorf("missing %v", column)
+		}
+		values[column] = record[idx]
+	}
+	base, err := tokens.Resolve(values["baseToken"])
+	if err != nil {
+		return nil, err
+	}
+	quote, err := tokens.Resolve(values["quoteToken"])
+	if err != nil {
+		return nil, err
+	}
+	return orderFromPrice(strings.ToLower(values["side"]), base, quote, values["price"], values["size"])
 }
 
 func CSVMain(inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
+	return CSVTokensMain(inputFile, outputFile, NewTokenRegistry(nil), false, new(big.Rat))
+}
+
+// CSVTokensMain parses orders out of a CSV, resolving token symbols, decimal
+// amounts and price based orders with the token registry.
+func CSVTokensMain(inputFile io.Reader, outputFile io.Writer, tokens *TokenRegistry, decimalAmounts bool, priceTolerance *big.Rat) subcommands.ExitStatus {
 	csvReader := csv.NewReader(inputFile)
 	headers, err := csvReader.Read()
 	if err != nil {
@@ -75,6 +178,7 @@ func CSVMain(inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
 		}
 		order := &types.Order{}
 		order.Initialize()
+		var makerToken, takerToken *Token
 		if idx, ok := headerMap["maker"]; ok {
 			var addressBytes []byte
 			if record[idx] == "" {
@@ -90,21 +194,17 @@ func CSVMain(inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
 		}
 		if idx, ok := headerMap["makerTokenAddress"]; ok {
 			var addressBytes []byte
-			if record[idx] == "" {
-				addressBytes = []byte{}
-			} else {
-				addressBytes, err = hex.DecodeString(record[idx][2:])
-				if err != nil {
-					log.Printf("Error parsing maker makerTokenAddress record %v: %v. Dropping Record.", counter, err.Error())
-					continue
-				}
+			addressBytes, makerToken, err = csvToken(record[idx], tokens, decimalAmounts)
+			if err != nil {
+				log.Printf("Error parsing maker makerTokenAddress record %v: %v. Dropping Record.", counter, err.Error())
+				continue
 			}
 			copy(order.MakerToken[:], addressBytes)
 		}
 		if idx, ok := headerMap["makerTokenAmount"]; ok {
-			value, ok := new(big.Int).SetString(record[idx], 10)
-			if !ok {
-				log.Printf("Error parsing  makerTokenAmount in record %v: %v. Dropping Record.", counter, record[idx])
+			value, err := tokenAmount(record[idx], makerToken)
+			if err != nil {
+				log.Printf("Error parsing  makerTokenAmount in record %v: %v. Dropping Record.", counter, err.Error())
 				continue
 			}
 			copy(order.MakerTokenAmount[:], abi.U256(value))
@@ -132,21 +232,17 @@ func CSVMain(inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
 		}
 		if idx, ok := headerMap["takerTokenAddress"]; ok {
 			var addressBytes []byte
-			if record[idx] == "" {
-				addressBytes = []byte{}
-			} else {
-				addressBytes, err = hex.DecodeString(record[idx][2:])
-				if err != nil {
-					log.Printf("Error parsing maker takerTokenAddress record %v: %v. Dropping Record.", counter, err.Error())
-					continue
-				}
+			addressBytes, takerToken, err = csvToken(record[idx], tokens, decimalAmounts)
+			if err != nil {
+				log.Printf("Error parsing maker takerTokenAddress record %v: %v. Dropping Record.", counter, err.Error())
+				continue
 			}
 			copy(order.TakerToken[:], addressBytes)
 		}
 		if idx, ok := headerMap["takerTokenAmount"]; ok {
-			value, ok := new(big.Int).SetString(record[idx], 10)
-			if !ok {
-				log.Printf("Error parsing  takerTokenAmount in record %v: %v. Dropping Record.", counter, record[idx])
+			value, err := tokenAmount(record[idx], takerToken)
+			if err != nil {
+				log.Printf("Error parsing  takerTokenAmount in record %v: %v. Dropping Record.", counter, err.Error())
 				continue
 			}
 			copy(order.TakerTokenAmount[:], abi.U256(value))
@@ -225,6 +321,21 @@ func CSVMain(inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
 			}
 			copy(order.ExchangeAddress[:], addressBytes)
 		}
+		if idx, ok := headerMap["side"]; ok && record[idx] != "" {
+			amounts, err := csvPriceOrder(record, headerMap, tokens)
+			if err != nil {
+				log.Printf("Error computing amounts for record %v: %v. Dropping Record.", counter, err.Error())
+				continue
+			}
+			copy(order.MakerToken[:], amounts.makerToken.address[:])
+			copy(order.TakerToken[:], amounts.takerToken.address[:])
+			copy(order.MakerTokenAmount[:], abi.U256(amounts.makerAmount))
+			copy(order.TakerTokenAmount[:], abi.U256(amounts.takerAmount))
+			if amounts.deviation.Cmp(priceTolerance) > 0 {
+				percent := new(big.Rat).Mul(amounts.deviation, big.NewRat(100, 1))
+				log.Printf("Warning: rounding moves the price of record %v by %v%%", counter, percent.FloatString(4))
+			}
+		}
 		utils.WriteRecord(order, outputFile)
 	}
 	return subcommands.ExitSuccess
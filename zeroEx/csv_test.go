@@ -7,7 +7,9 @@ import (
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/zeroEx"
 	"github.com/notegio/openrelay/types"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"testing"
 )
 
@@ -75,3 +77,100 @@ func TestCsvAllFieldsProcess(t *testing.T) {
 		t.Errorf("Unexpected takerTokenAmount: %v", takerTokenAmount)
 	}
 }
+
+func TestCsvTokenSymbols(t *testing.T) {
+	registryFile, err := ioutil.TempFile("", "tokens")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(registryFile.Name())
+	registryFile.WriteString(`[
+  {"symbol": "ZRX", "address": "0xa1df88ea6a08722055250ed65601872e59cddfaa", "decimals": 18},
+  {"symbol": "USDC", "address": "0xc778417e063141139fce010982780140aa0cd5ab", "decimals": 6}
+]`)
+	registryFile.Close()
+	tokens := zeroEx.NewTokenRegistry(nil)
+	if err := tokens.LoadFile(registryFile.Name()); err != nil {
+		t.Fatal(err.Error())
+	}
+	// The second record has more precision than USDC allows, and should be
+	// dropped
+	csvData := `makerTokenAddress,makerTokenAmount,takerTokenAddress,takerTokenAmount
+zrx,1.5,USDC,2.25
+ZRX,1,USDC,0.0000001
+`
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.CSVTokensMain(bytes.NewReader([]byte(csvData)), outputBuffer, tokens, false, new(big.Rat)); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	processedOrder := &types.Order{}
+	if err := json.Unmarshal(outputBuffer.Bytes(), processedOrder); err != nil {
+		t.Fatalf("Error parsing '%v': %v", outputBuffer.String(), err.Error())
+	}
+	if processedOrder.MakerToken.String() != "0xa1df88ea6a08722055250ed65601872e59cddfaa" {
+		t.Errorf("Unexpected MakerToken value: %v", processedOrder.MakerToken)
+	}
+	if processedOrder.TakerToken.String() != "0xc778417e063141139fce010982780140aa0cd5ab" {
+		t.Errorf("Unexpected TakerToken value: %v", processedOrder.TakerToken)
+	}
+	if makerTokenAmount := new(big.Int).SetBytes(processedOrder.MakerTokenAmount[:]); makerTokenAmount.Cmp(big.NewInt(1500000000000000000)) != 0 {
+		t.Errorf("Unexpected makerTokenAmount: %v", makerTokenAmount)
+	}
+	if takerTokenAmount := new(big.Int).SetBytes(processedOrder.TakerTokenAmount[:]); takerTokenAmount.Cmp(big.NewInt(2250000)) != 0 {
+		t.Errorf("Unexpected takerTokenAmount: %v", takerTokenAmount)
+	}
+}
+
+func TestCsvPriceOrders(t *testing.T) {
+	tokens := zeroEx.NewTokenRegistry(nil)
+	var zrxDecimals, usdcDecimals uint8 = 18, 6
+	if err := tokens.Add(&zeroEx.Token{Symbol: "ZRX", Address: "0xa1df88ea6a08722055250ed65601872e59cddfaa", Decimals: &zrxDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := tokens.Add(&zeroEx.Token{Symbol: "USDC", Address: "0xc778417e063141139fce010982780140aa0cd5ab", Decimals: &usdcDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	// Selling 2 ZRX at 0.3333333 is 0.6666666 USDC, while buying 0.0000001 ZRX
+	// at 0.5 rounds the maker's USDC amount down to zero, so is dropped.
+	csvData := `side,baseToken,quoteToken,price,size
+sell,ZRX,USDC,0.3333333,2
+buy,ZRX,USDC,1.25,4
+buy,ZRX,USDC,0.5,0.0000001
+`
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.CSVTokensMain(bytes.NewReader([]byte(csvData)), outputBuffer, tokens, false, big.NewRat(1, 10000)); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	orders := []*types.Order{}
+	for scanner.Scan() {
+		processedOrder := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), processedOrder); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		orders = append(orders, processedOrder)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("Expected 2 orders, got %v", len(orders))
+	}
+	sell, buy := orders[0], orders[1]
+	if sell.MakerToken.String() != "0xa1df88ea6a08722055250ed65601872e59cddfaa" {
+		t.Errorf("Unexpected sell MakerToken value: %v", sell.MakerToken)
+	}
+	if makerTokenAmount := new(big.Int).SetBytes(sell.MakerTokenAmount[:]); makerTokenAmount.Cmp(big.NewInt(2000000000000000000)) != 0 {
+		t.Errorf("Unexpected sell makerTokenAmount: %v", makerTokenAmount)
+	}
+	// 0.6666666 USDC, rounded up to the maker's favor
+	if takerTokenAmount := new(big.Int).SetBytes(sell.TakerTokenAmount[:]); takerTokenAmount.Cmp(big.NewInt(666667)) != 0 {
+		t.Errorf("Unexpected sell takerTokenAmount: %v", takerTokenAmount)
+	}
+	if buy.MakerToken.String() != "0xc778417e063141139fce010982780140aa0cd5ab" {
+		t.Errorf("Unexpected buy MakerToken value: %v", buy.MakerToken)
+	}
+	if makerTokenAmount := new(big.Int).SetBytes(buy.MakerTokenAmount[:]); makerTokenAmount.Cmp(big.NewInt(5000000)) != 0 {
+		t.Errorf("Unexpected buy makerTokenAmount: %v", makerTokenAmount)
+	}
+	if takerTokenAmount := new(big.Int).SetBytes(buy.TakerTokenAmount[:]); takerTokenAmount.Cmp(big.NewInt(4000000000000000000)) != 0 {
+		t.Errorf("Unexpected buy takerTokenAmount: %v", takerTokenAmount)
+	}
+}
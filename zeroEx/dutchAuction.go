@@ -0,0 +1,244 @@
+package zeroEx
+
+import (
+	"context"
+	"flag"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+)
+
+type dutchAuction struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	tokenFiles     string
+	rpcURL         string
+	startPrice     string
+	endPrice       string
+	steps          int
+	start          int64
+	duration       int64
+	priceTolerance string
+	schedule       bool
+	keyFile        string
+	targetURL      string
+	keyOpts        utils.KeyOptions
+}
+
+func (p *dutchAuction) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *dutchAuction) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*dutchAuction) Name() string     { return "dutchAuction" }
+func (*dutchAuction) Synopsis() string { return "Create or run a descending price auction" }
+func (*dutchAuction) Usage() string {
+	return `msv 0x dutchAuction --start-price PRICE --end-price PRICE --steps N --duration SECONDS [--start TIME] [--tokens FILE[,FILE...]] [--rpc ETHEREUM_RPC_URL] [--input FILE] [--output FILE]:
+msv 0x dutchAuction --schedule --key KEY_FILE [--target RELAYER_URL] [--start TIME] [--password-file FILE] [--password-env VAR] [--input FILE] [--output FILE]:
+  Without --schedule, read a single base order and write a series of N
+  copies of it whose prices fall evenly from the start price to the end
+  price. Prices are in taker tokens per maker token, and the maker token
+  amount is kept while the taker token amount is adjusted. The auction starts
+  at TIME (a unix timestamp, default now) and lasts SECONDS, split into N
+  windows of equal length. Each order expires when its window closes.
+
+  The series can be passed through getFees and setSalt like any other
+  orders. With --schedule, read a series and sign and upload each order to
+  the target relayer only when its window opens, which is when the previous
+  order expires. The first window opens at TIME. Orders whose window has
+  already closed are skipped, and each uploaded order is written to the
+  output.
+`
+}
+
+func (p *dutchAuction) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.tokenFiles, "tokens", "", "Comma separated token registry files")
+	f.StringVar(&p.rpcURL, "rpc", "", "Ethereum RPC URL for looking up token decimals")
+	f.StringVar(&p.startPrice, "start-price", "", "The price of the first step, in taker tokens per maker token")
+	f.StringVar(&p.endPrice, "end-price", "", "The price of the last step, in taker tokens per maker token")
+	f.IntVar(&p.steps, "steps", 0, "The number of orders in the auction")
+	f.Int64Var(&p.start, "start", 0, "The unix timestamp the auction starts at [now]")
+	f.Int64Var(&p.duration, "duration", 0, "The length of the auction, in seconds")
+	f.StringVar(&p.priceTolerance, "price-tolerance", "0.0001", "Warn when rounding moves a step's price by more than this fraction")
+	f.BoolVar(&p.schedule, "schedule", false, "Sign and upload each order of a series when its window opens")
+	f.StringVar(&p.keyFile, "key", "", "With --schedule, the key file or keystore directory to sign orders with")
+	f.StringVar(&p.targetURL, "target", "https://api.openrelay.xyz", "With --schedule, the target 0x relayer")
+	p.keyOpts.SetFlags(f)
+}
+
+func (p *dutchAuction) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	start := p.start
+	if start == 0 {
+		start = time.Now().Unix()
+	}
+	if p.schedule {
+		if p.keyFile == "" {
+			os.Stderr.WriteString(p.Usage())
+			return subcommands.ExitUsageError
+		}
+		utils.SetIO(p)
+		keys, err := p.keyOpts.Load(p.keyFile)
+		if err != nil {
+			log.Printf("Error loading key: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+		return DutchAuctionScheduleMain(p.inputFile, p.outputFile, keys, NewUploader(p.targetURL, 30*time.Second, 3, 0), start)
+	}
+	if p.startPrice == "" || p.endPrice == "" {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	tokens, err := loadTokenRegistry(p.rpcURL, p.tokenFiles)
+	if err != nil {
+		log.Printf("Error loading token registry: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	startPrice, err := parseDecimal(p.startPrice)
+	if err != nil {
+		log.Printf("Error parsing start price: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	endPrice, err := parseDecimal(p.endPrice)
+	if err != nil {
+		log.Printf("Error parsing end price: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	priceTolerance, ok := new(big.Rat).SetString(p.priceTolerance)
+	if !ok {
+		log.Printf("Invalid price tolerance: %v", p.priceTolerance)
+		return subcommands.ExitUsageError
+	}
+	return DutchAuctionMain(p.inputFile, p.outputFile, tokens, startPrice, endPrice, p.steps, start, p.duration, priceTolerance)
+}
+
+// auctionWindowEnd returns when the step'th of steps equal windows, dividing
+// duration seconds from start, closes. Rounding down keeps the last window's
+// end at exactly start + duration.
+func auctionWindowEnd(start, duration int64, step, steps int) int64 {
+	return start + duration*int64(step+1)/int64(steps)
+}
+
+// DutchAuctionMain reads one base order and writes a series of steps orders
+// with falling prices and consecutive expirations
+func DutchAuctionMain(inputFile io.Reader, outputFile io.Writer, tokens *TokenRegistry, startPrice, endPrice *big.Rat, steps int, start, duration int64, priceTolerance *big.Rat) subcommands.ExitStatus {
+	if steps < 1 {
+		log.Printf("--steps must be at least 1")
+		return subcommands.ExitFailure
+	}
+	if duration < int64(steps) {
+		log.Printf("--duration must allow at least one second per step")
+		return subcommands.ExitFailure
+	}
+	if steps > 1 && startPrice.Cmp(endPrice) <= 0 {
+		log.Printf("The start price must be higher than the end price")
+		return subcommands.ExitFailure
+	}
+	var base *types.Order
+	for order := range orderScanner(inputFile) {
+		if base != nil {
+			log.Printf("Expected a single base order")
+			return subcommands.ExitFailure
+		}
+		base = order
+	}
+	if base == nil {
+		log.Printf("No base order found")
+		return subcommands.ExitFailure
+	}
+	makerToken, err := tokens.Lookup(base.MakerToken)
+	if err != nil {
+		log.Printf("Error looking up maker token: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	takerToken, err := tokens.Lookup(base.TakerToken)
+	if err != nil {
+		log.Printf("Error looking up taker token: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	makerTokenAmount := new(big.Int).SetBytes(base.MakerTokenAmount[:])
+	for i := 0; i < steps; i++ {
+		price := ladderPrice(startPrice, endPrice, i, steps)
+		amounts, err := orderFromPriceAmount(SideSell, makerToken, takerToken, price, makerTokenAmount)
+		if err != nil {
+			log.Printf("Error creating step %v at price %v: %v", i+1, price.FloatString(8), err.Error())
+			return subcommands.ExitFailure
+		}
+		if amounts.deviation.Cmp(priceTolerance) > 0 {
+			percent := new(big.Rat).Mul(amounts.deviation, big.NewRat(100, 1))
+			log.Printf("Warning: rounding moves the price of step %v by %v%%", i+1, percent.FloatString(4))
+		}
+		// The copy shares the base order's fields, except for the ones
+		// changed for each step
+		order := *base
+		order.TakerTokenAmount = &types.Uint256{}
+		order.ExpirationTimestampInSec = &types.Uint256{}
+		order.Signature = &types.Signature{}
+		copy(order.TakerTokenAmount[:], abi.U256(amounts.takerAmount))
+		copy(order.ExpirationTimestampInSec[:], abi.U256(big.NewInt(auctionWindowEnd(start, duration, i, steps))))
+		utils.WriteRecord(&order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
+
+// DutchAuctionScheduleMain reads an auction series and signs and uploads each
+// order when the previous one expires, starting at start
+func DutchAuctionScheduleMain(inputFile io.Reader, outputFile io.Writer, keys utils.KeyLookup, uploader *Uploader, start int64) subcommands.ExitStatus {
+	series := []*types.Order{}
+	for order := range orderScanner(inputFile) {
+		series = append(series, order)
+	}
+	if len(series) == 0 {
+		log.Printf("No orders found")
+		return subcommands.ExitFailure
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return new(big.Int).SetBytes(series[i].ExpirationTimestampInSec[:]).Cmp(new(big.Int).SetBytes(series[j].ExpirationTimestampInSec[:])) < 0
+	})
+	opens := start
+	for i, order := range series {
+		expiration := new(big.Int).SetBytes(order.ExpirationTimestampInSec[:]).Int64()
+		windowOpens := opens
+		opens = expiration
+		if expiration <= time.Now().Unix() {
+			log.Printf("Skipping step %v, whose window closed at %v", i+1, time.Unix(expiration, 0))
+			continue
+		}
+		if wait := time.Until(time.Unix(windowOpens, 0)); wait > 0 {
+			log.Printf("Waiting until %v to upload step %v", time.Unix(windowOpens, 0), i+1)
+			time.Sleep(wait)
+		}
+		key, err := keys.Get(orCommon.ToGethAddress(order.Maker))
+		if err != nil {
+			log.Printf("Error signing step %v: %v", i+1, err.Error())
+			return subcommands.ExitFailure
+		}
+		signOrderWithKey(order, key)
+		if result := uploader.Upload(order); !result.ok() {
+			log.Printf("Error uploading step %v to %v: status %v: %v", i+1, uploader.TargetURL, result.status, result.err)
+			return subcommands.ExitFailure
+		}
+		log.Printf("Uploaded step %v at price %v, expiring at %v", i+1, orderPrice(order).FloatString(8), time.Unix(expiration, 0))
+		utils.WriteRecord(order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
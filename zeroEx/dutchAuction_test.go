@@ -0,0 +1,56 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+)
+
+func TestDutchAuctionSeries(t *testing.T) {
+	tokens := zeroEx.NewTokenRegistry(nil)
+	var zrxDecimals, wethDecimals uint8 = 18, 18
+	if err := tokens.Add(&zeroEx.Token{Symbol: "ZRX", Address: "0xa1df88ea6a08722055250ed65601872e59cddfaa", Decimals: &zrxDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := tokens.Add(&zeroEx.Token{Symbol: "WETH", Address: "0xc778417e063141139fce010982780140aa0cd5ab", Decimals: &wethDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	base := "{\"maker\":\"0x324454186bb728a3ea55750e0618ff1b18ce6cf8\",\"taker\":\"0x0000000000000000000000000000000000000000\",\"makerFee\":\"0\",\"takerFee\":\"0\",\"makerTokenAmount\":\"10000000000000000000\",\"takerTokenAmount\":\"1\",\"makerTokenAddress\":\"0xa1df88ea6a08722055250ed65601872e59cddfaa\",\"takerTokenAddress\":\"0xc778417e063141139fce010982780140aa0cd5ab\",\"salt\":\"0\",\"feeRecipient\":\"0x0000000000000000000000000000000000000000\",\"exchangeContractAddress\":\"0x90fe2af704b34e0224bf2299c838e04d4dcf1364\",\"expirationUnixTimestampSec\":\"0\",\"ecSignature\":{\"v\":0,\"r\":\"0x0000000000000000000000000000000000000000000000000000000000000000\",\"s\":\"0x0000000000000000000000000000000000000000000000000000000000000000\"}}\n"
+	outputBuffer := &bytes.Buffer{}
+	status := zeroEx.DutchAuctionMain(bytes.NewBufferString(base), outputBuffer, tokens, big.NewRat(3, 1), big.NewRat(1, 1), 3, 1000, 300, big.NewRat(1, 10000))
+	if status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	// 10 ZRX at 3, 2 and 1 WETH each, in consecutive 100 second windows
+	expectedTaker := []string{"30000000000000000000", "20000000000000000000", "10000000000000000000"}
+	expectedExpiration := []string{"1100", "1200", "1300"}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		order := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		if counter >= len(expectedTaker) {
+			t.Fatalf("Too many orders")
+		}
+		if makerTokenAmount := new(big.Int).SetBytes(order.MakerTokenAmount[:]).String(); makerTokenAmount != "10000000000000000000" {
+			t.Errorf("Step %v: unexpected makerTokenAmount %v", counter, makerTokenAmount)
+		}
+		if takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:]).String(); takerTokenAmount != expectedTaker[counter] {
+			t.Errorf("Step %v: unexpected takerTokenAmount %v", counter, takerTokenAmount)
+		}
+		if expiration := new(big.Int).SetBytes(order.ExpirationTimestampInSec[:]).String(); expiration != expectedExpiration[counter] {
+			t.Errorf("Step %v: unexpected expiration %v", counter, expiration)
+		}
+		counter++
+	}
+	if counter != 3 {
+		t.Errorf("Expected 3 orders, got %v", counter)
+	}
+}
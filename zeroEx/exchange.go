@@ -0,0 +1,154 @@
+package zeroEx
+
+import (
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"strings"
+)
+
+// exchangeErrors describes the errorId values of the Exchange contract's
+// LogError event, in the order of the contract's Errors enum.
+var exchangeErrors = []string{
+	"order has expired",
+	"order has already been fully filled or cancelled",
+	"rounding error too large",
+	"insufficient balance or allowance",
+}
+
+var exchangeABI abi.ABI
+
+func init() {
+	parsed, err := abi.JSON(strings.NewReader(exchangecontract.ExchangeABI))
+	if err != nil {
+		panic(err)
+	}
+	exchangeABI = parsed
+}
+
+// orderAddresses returns the order's addresses in the layout expected by the
+// exchange contract: maker, taker, makerToken, takerToken, feeRecipient
+func orderAddresses(order *types.Order) [5]common.Address {
+	return [5]common.Address{
+		orCommon.ToGethAddress(order.Maker),
+		orCommon.ToGethAddress(order.Taker),
+		orCommon.ToGethAddress(order.MakerToken),
+		orCommon.ToGethAddress(order.TakerToken),
+		orCommon.ToGethAddress(order.FeeRecipient),
+	}
+}
+
+// orderValues returns the order's values in the layout expected by the
+// exchange contract: makerTokenAmount, takerTokenAmount, makerFee, takerFee,
+// expirationTimestampInSec, salt
+func orderValues(order *types.Order) [6]*big.Int {
+	return [6]*big.Int{
+		new(big.Int).SetBytes(order.MakerTokenAmount[:]),
+		new(big.Int).SetBytes(order.TakerTokenAmount[:]),
+		new(big.Int).SetBytes(order.MakerFee[:]),
+		new(big.Int).SetBytes(order.TakerFee[:]),
+		new(big.Int).SetBytes(order.ExpirationTimestampInSec[:]),
+		new(big.Int).SetBytes(order.Salt[:]),
+	}
+}
+
+// batchOrderArgs returns the addresses, values and signature components of
+// several orders, as expected by the exchange contract's batch functions.
+func batchOrderArgs(orders []*types.Order) ([][5]common.Address, [][6]*big.Int, []uint8, [][32]byte, [][32]byte) {
+	addresses := make([][5]common.Address, len(orders))
+	values := make([][6]*big.Int, len(orders))
+	v := make([]uint8, len(orders))
+	r := make([][32]byte, len(orders))
+	s := make([][32]byte, len(orders))
+	for i, order := range orders {
+		addresses[i] = orderAddresses(order)
+		values[i] = orderValues(order)
+		v[i] = order.Signature.V
+		r[i] = order.Signature.R
+		s[i] = order.Signature.S
+	}
+	return addresses, values, v, r, s
+}
+
+// rawExchangeTransact calls an exchange contract method through the raw
+// transactor. The generated bindings for the batch functions take a single
+// order's addresses and values rather than arrays of them, so they can't
+// pass the arguments the contract expects.
+func rawExchangeTransact(exchange *exchangecontract.Exchange, opts *bind.TransactOpts, method string, args ...interface{}) (*gethTypes.Transaction, error) {
+	raw := &exchangecontract.ExchangeTransactorRaw{Contract: &exchange.ExchangeTransactor}
+	return raw.Transact(opts, method, args...)
+}
+
+func orderHash(order *types.Order) [32]byte {
+	hash := [32]byte{}
+	copy(hash[:], order.Hash())
+	return hash
+}
+
+// exchangeLogs summarizes the exchange events in a transaction receipt,
+// keyed by order hash.
+type exchangeLogs struct {
+	filled    map[[32]byte]*big.Int
+	cancelled map[[32]byte]*big.Int
+	errors    map[[32]byte]string
+}
+
+// parseExchangeLogs reads LogFill, LogCancel and LogError events out of a
+// receipt. LogFill and LogCancel carry the order hash as their last
+// non-indexed field, with the taker token amount a few words before it.
+func parseExchangeLogs(receipt *gethTypes.Receipt) *exchangeLogs {
+	result := &exchangeLogs{
+		make(map[[32]byte]*big.Int),
+		make(map[[32]byte]*big.Int),
+		make(map[[32]byte]string),
+	}
+	fillID := exchangeABI.Events["LogFill"].Id()
+	cancelID := exchangeABI.Events["LogCancel"].Id()
+	errorID := exchangeABI.Events["LogError"].Id()
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+		hash := [32]byte{}
+		switch log.Topics[0] {
+		case fillID:
+			if len(log.Data) < 8*32 {
+				continue
+			}
+			copy(hash[:], log.Data[7*32:8*32])
+			amount := new(big.Int).SetBytes(log.Data[4*32 : 5*32])
+			if previous, ok := result.filled[hash]; ok {
+				amount.Add(amount, previous)
+			}
+			result.filled[hash] = amount
+		case cancelID:
+			if len(log.Data) < 5*32 {
+				continue
+			}
+			copy(hash[:], log.Data[4*32:5*32])
+			amount := new(big.Int).SetBytes(log.Data[3*32 : 4*32])
+			if previous, ok := result.cancelled[hash]; ok {
+				amount.Add(amount, previous)
+			}
+			result.cancelled[hash] = amount
+		case errorID:
+			if len(log.Topics) < 3 {
+				continue
+			}
+			copy(hash[:], log.Topics[2][:])
+			errorCode := new(big.Int).SetBytes(log.Topics[1][:]).Int64()
+			if errorCode < int64(len(exchangeErrors)) {
+				result.errors[hash] = exchangeErrors[errorCode]
+			} else {
+				result.errors[hash] = fmt.Sprintf("unknown exchange error %v", errorCode)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,651 @@
+package zeroEx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The expression language used by filter and set works on numbers, booleans,
+// byte strings and text. Numbers are exact rationals, so arithmetic never
+// loses precision, byte strings hold addresses and hashes, and text holds
+// values such as the state status adds to records.
+
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprBool
+	exprBytes
+	exprString
+)
+
+func (kind exprKind) String() string {
+	switch kind {
+	case exprNumber:
+		return "number"
+	case exprBool:
+		return "boolean"
+	case exprString:
+		return "string"
+	default:
+		return "bytes"
+	}
+}
+
+type exprValue struct {
+	kind    exprKind
+	number  *big.Rat
+	boolean bool
+	bytes   []byte
+	text    string
+}
+
+func numberValue(value *big.Rat) exprValue { return exprValue{kind: exprNumber, number: value} }
+func boolValue(value bool) exprValue       { return exprValue{kind: exprBool, boolean: value} }
+func bytesValue(value []byte) exprValue    { return exprValue{kind: exprBytes, bytes: value} }
+func stringValue(value string) exprValue   { return exprValue{kind: exprString, text: value} }
+func uint256Value(value *types.Uint256) exprValue {
+	return numberValue(new(big.Rat).SetInt(new(big.Int).SetBytes(value[:])))
+}
+
+func (value exprValue) String() string {
+	switch value.kind {
+	case exprNumber:
+		if value.number.IsInt() {
+			return value.number.Num().String()
+		}
+		return value.number.RatString()
+	case exprBool:
+		return fmt.Sprintf("%v", value.boolean)
+	case exprString:
+		return fmt.Sprintf("%q", value.text)
+	default:
+		return fmt.Sprintf("%#x", value.bytes)
+	}
+}
+
+// exprEnv is what an expression is evaluated against. record holds the
+// fields of the input record, if there is one.
+type exprEnv struct {
+	order  *types.Order
+	record map[string]interface{}
+	now    int64
+}
+
+// orderField describes an order field as seen by expressions. Fields are named
+// as they are in order records. set checks that a value fits the field before
+// storing it.
+type orderField struct {
+	get func(*types.Order) exprValue
+	set func(*types.Order, exprValue) error
+}
+
+func addressField(field func(*types.Order) *types.Address) orderField {
+	return orderField{
+		get: func(order *types.Order) exprValue { return bytesValue(field(order)[:]) },
+		set: func(order *types.Order, value exprValue) error {
+			return setBytes(field(order)[:], value, "an address")
+		},
+	}
+}
+
+func uint256Field(field func(*types.Order) *types.Uint256) orderField {
+	return orderField{
+		get: func(order *types.Order) exprValue { return uint256Value(field(order)) },
+		set: func(order *types.Order, value exprValue) error {
+			integer, err := integerValue(value, maxUint256)
+			if err != nil {
+				return err
+			}
+			copy(field(order)[:], abi.U256(integer))
+			return nil
+		},
+	}
+}
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// integerValue checks that value is an integer from zero to max
+func integerValue(value exprValue, max *big.Int) (*big.Int, error) {
+	if value.kind != exprNumber {
+		return nil, fmt.Errorf("expected a number, not a %v", value.kind)
+	}
+	if !value.number.IsInt() {
+		return nil, fmt.Errorf("%v is not an integer, use floor() or ceil() to round it", value.number.FloatString(18))
+	}
+	integer := value.number.Num()
+	if integer.Sign() < 0 || integer.Cmp(max) > 0 {
+		return nil, fmt.Errorf("%v is out of range", integer)
+	}
+	return integer, nil
+}
+
+// setBytes copies value into field, which it must exactly fit
+func setBytes(field []byte, value exprValue, description string) error {
+	if value.kind != exprBytes || len(value.bytes) != len(field) {
+		return fmt.Errorf("%v is not %v", value, description)
+	}
+	copy(field, value.bytes)
+	return nil
+}
+
+var orderFields = map[string]orderField{
+	"maker":                      addressField(func(order *types.Order) *types.Address { return order.Maker }),
+	"taker":                      addressField(func(order *types.Order) *types.Address { return order.Taker }),
+	"makerTokenAddress":          addressField(func(order *types.Order) *types.Address { return order.MakerToken }),
+	"takerTokenAddress":          addressField(func(order *types.Order) *types.Address { return order.TakerToken }),
+	"feeRecipient":               addressField(func(order *types.Order) *types.Address { return order.FeeRecipient }),
+	"exchangeContractAddress":    addressField(func(order *types.Order) *types.Address { return order.ExchangeAddress }),
+	"makerTokenAmount":           uint256Field(func(order *types.Order) *types.Uint256 { return order.MakerTokenAmount }),
+	"takerTokenAmount":           uint256Field(func(order *types.Order) *types.Uint256 { return order.TakerTokenAmount }),
+	"makerFee":                   uint256Field(func(order *types.Order) *types.Uint256 { return order.MakerFee }),
+	"takerFee":                   uint256Field(func(order *types.Order) *types.Uint256 { return order.TakerFee }),
+	"expirationUnixTimestampSec": uint256Field(func(order *types.Order) *types.Uint256 { return order.ExpirationTimestampInSec }),
+	"salt":                       uint256Field(func(order *types.Order) *types.Uint256 { return order.Salt }),
+	"takerTokenAmountFilled":     uint256Field(func(order *types.Order) *types.Uint256 { return order.TakerTokenAmountFilled }),
+	"takerTokenAmountCancelled":  uint256Field(func(order *types.Order) *types.Uint256 { return order.TakerTokenAmountCancelled }),
+	"v": {
+		get: func(order *types.Order) exprValue { return numberValue(big.NewRat(int64(order.Signature.V), 1)) },
+		set: func(order *types.Order, value exprValue) error {
+			v, err := integerValue(value, big.NewInt(255))
+			if err != nil {
+				return err
+			}
+			order.Signature.V = byte(v.Uint64())
+			return nil
+		},
+	},
+	"r": {
+		get: func(order *types.Order) exprValue { return bytesValue(order.Signature.R[:]) },
+		set: func(order *types.Order, value exprValue) error {
+			return setBytes(order.Signature.R[:], value, "32 bytes")
+		},
+	},
+	"s": {
+		get: func(order *types.Order) exprValue { return bytesValue(order.Signature.S[:]) },
+		set: func(order *types.Order, value exprValue) error {
+			return setBytes(order.Signature.S[:], value, "32 bytes")
+		},
+	},
+}
+
+// derivedValues are values computed from the whole order
+var derivedValues = map[string]func(env *exprEnv) exprValue{
+	// price is the number of taker tokens per maker token, in base units
+	"price": func(env *exprEnv) exprValue { return numberValue(orderPrice(env.order)) },
+	"hash":  func(env *exprEnv) exprValue { return bytesValue(env.order.Hash()) },
+	"expired": func(env *exprEnv) exprValue {
+		expiration := new(big.Int).SetBytes(env.order.ExpirationTimestampInSec[:])
+		return boolValue(expiration.Cmp(big.NewInt(env.now)) <= 0)
+	},
+	// isSigned is true when the order carries a valid signature from its
+	// maker. Verify logs an error for empty signatures, so they're skipped.
+	"isSigned": func(env *exprEnv) exprValue {
+		if env.order.Signature.V == 0 {
+			return boolValue(false)
+		}
+		return boolValue(env.order.Signature.Verify(env.order.Maker))
+	},
+}
+
+// recordValues are annotations that earlier commands add to order records
+var recordValues = map[string]string{
+	"state": "msv 0x status",
+}
+
+// exprFunctions are the functions expressions may call
+var exprFunctions = map[string]func(env *exprEnv, args []exprValue) (exprValue, error){
+	"now": func(env *exprEnv, args []exprValue) (exprValue, error) {
+		if len(args) != 0 {
+			return exprValue{}, errors.New("now() takes no arguments")
+		}
+		return numberValue(big.NewRat(env.now, 1)), nil
+	},
+	// big.Int's Div rounds towards negative infinity for positive divisors,
+	// and a big.Rat's denominator is always positive
+	"floor": roundFunction("floor", func(value *big.Rat) *big.Int {
+		return new(big.Int).Div(value.Num(), value.Denom())
+	}),
+	"ceil": roundFunction("ceil", func(value *big.Rat) *big.Int {
+		result, remainder := new(big.Int).DivMod(value.Num(), value.Denom(), new(big.Int))
+		if remainder.Sign() != 0 {
+			result.Add(result, big.NewInt(1))
+		}
+		return result
+	}),
+}
+
+func roundFunction(name string, round func(*big.Rat) *big.Int) func(env *exprEnv, args []exprValue) (exprValue, error) {
+	return func(env *exprEnv, args []exprValue) (exprValue, error) {
+		if len(args) != 1 || args[0].kind != exprNumber {
+			return exprValue{}, fmt.Errorf("%v() takes one number", name)
+		}
+		return numberValue(new(big.Rat).SetInt(round(args[0].number))), nil
+	}
+}
+
+// expr is a parsed expression
+type expr interface {
+	eval(env *exprEnv) (exprValue, error)
+}
+
+type literalExpr struct{ value exprValue }
+
+func (node *literalExpr) eval(env *exprEnv) (exprValue, error) { return node.value, nil }
+
+type identExpr struct{ name string }
+
+func (node *identExpr) eval(env *exprEnv) (exprValue, error) {
+	if field, ok := orderFields[node.name]; ok {
+		return field.get(env.order), nil
+	}
+	if command, ok := recordValues[node.name]; ok {
+		value, ok := env.record[node.name].(string)
+		if !ok {
+			return exprValue{}, fmt.Errorf("the record has no %v, which is added by %v", node.name, command)
+		}
+		return stringValue(value), nil
+	}
+	return derivedValues[node.name](env), nil
+}
+
+type callExpr struct {
+	name string
+	args []expr
+}
+
+func (node *callExpr) eval(env *exprEnv) (exprValue, error) {
+	args := make([]exprValue, len(node.args))
+	for i, arg := range node.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		args[i] = value
+	}
+	return exprFunctions[node.name](env, args)
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+func (node *unaryExpr) eval(env *exprEnv) (exprValue, error) {
+	value, err := node.operand.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	switch node.op {
+	case "!":
+		if value.kind != exprBool {
+			return exprValue{}, fmt.Errorf("cannot apply ! to a %v", value.kind)
+		}
+		return boolValue(!value.boolean), nil
+	default:
+		if value.kind != exprNumber {
+			return exprValue{}, fmt.Errorf("cannot negate a %v", value.kind)
+		}
+		return numberValue(new(big.Rat).Neg(value.number)), nil
+	}
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+func (node *binaryExpr) eval(env *exprEnv) (exprValue, error) {
+	left, err := node.left.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	// && and || short circuit
+	if node.op == "&&" || node.op == "||" {
+		if left.kind != exprBool {
+			return exprValue{}, fmt.Errorf("cannot apply %v to a %v", node.op, left.kind)
+		}
+		if left.boolean == (node.op == "||") {
+			return left, nil
+		}
+		right, err := node.right.eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		if right.kind != exprBool {
+			return exprValue{}, fmt.Errorf("cannot apply %v to a %v", node.op, right.kind)
+		}
+		return right, nil
+	}
+	right, err := node.right.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if left.kind != right.kind {
+		return exprValue{}, fmt.Errorf("cannot apply %v to a %v and a %v", node.op, left.kind, right.kind)
+	}
+	switch node.op {
+	case "==", "!=":
+		var equal bool
+		switch left.kind {
+		case exprNumber:
+			equal = left.number.Cmp(right.number) == 0
+		case exprBool:
+			equal = left.boolean == right.boolean
+		case exprString:
+			equal = left.text == right.text
+		default:
+			equal = bytes.Equal(left.bytes, right.bytes)
+		}
+		return boolValue(equal == (node.op == "==")), nil
+	}
+	if left.kind != exprNumber {
+		return exprValue{}, fmt.Errorf("cannot apply %v to a %v", node.op, left.kind)
+	}
+	a, b := left.number, right.number
+	switch node.op {
+	case "<":
+		return boolValue(a.Cmp(b) < 0), nil
+	case "<=":
+		return boolValue(a.Cmp(b) <= 0), nil
+	case ">":
+		return boolValue(a.Cmp(b) > 0), nil
+	case ">=":
+		return boolValue(a.Cmp(b) >= 0), nil
+	case "+":
+		return numberValue(new(big.Rat).Add(a, b)), nil
+	case "-":
+		return numberValue(new(big.Rat).Sub(a, b)), nil
+	case "*":
+		return numberValue(new(big.Rat).Mul(a, b)), nil
+	case "/":
+		if b.Sign() == 0 {
+			return exprValue{}, errors.New("division by zero")
+		}
+		return numberValue(new(big.Rat).Quo(a, b)), nil
+	default:
+		// % is only defined on integers
+		if !a.IsInt() || !b.IsInt() {
+			return exprValue{}, errors.New("% requires integers")
+		}
+		if b.Sign() == 0 {
+			return exprValue{}, errors.New("division by zero")
+		}
+		return numberValue(new(big.Rat).SetInt(new(big.Int).Mod(a.Num(), b.Num()))), nil
+	}
+}
+
+// evalOrder evaluates an expression against an order
+func evalOrder(expression expr, order *types.Order) (exprValue, error) {
+	return evalRecord(expression, &orderRecord{order: order})
+}
+
+// evalRecord evaluates an expression against an order record, which may
+// also use the record's annotations
+func evalRecord(expression expr, record *orderRecord) (exprValue, error) {
+	return expression.eval(&exprEnv{order: record.order, record: record.fields, now: time.Now().Unix()})
+}
+
+type exprToken struct {
+	kind  string // "number", "hex", "string", "ident", "op" or "end"
+	text  string
+	start int
+}
+
+func tokenizeExpr(source string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(source[i:], "0x"):
+			j := i + 2
+			for j < len(source) && strings.ContainsRune("0123456789abcdefABCDEF", rune(source[j])) {
+				j++
+			}
+			tokens = append(tokens, exprToken{"hex", source[i:j], i})
+			i = j
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) || source[j] == '.') {
+				j++
+			}
+			if j < len(source) && (source[j] == 'e' || source[j] == 'E') {
+				j++
+				for j < len(source) && unicode.IsDigit(rune(source[j])) {
+					j++
+				}
+			}
+			tokens = append(tokens, exprToken{"number", source[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			j := strings.IndexRune(source[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at position %v", i+1)
+			}
+			tokens = append(tokens, exprToken{"string", source[i+1 : i+1+j], i})
+			i += j + 2
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(source) && (unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j])) || source[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{"ident", source[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", ","} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected '%c' at position %v", c, i+1)
+			}
+			tokens = append(tokens, exprToken{"op", op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{"end", "", len(source)}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// parseExpr parses an expression such as
+//
+//	makerTokenAmount > 10e18 && expirationUnixTimestampSec > now()
+//
+// Expressions support the arithmetic operators + - * / and %, comparisons,
+// && || and !, numbers in decimal or exponent notation, hex literals for
+// addresses and hashes, quoted strings, order fields, the values in
+// derivedValues and recordValues, and the functions in exprFunctions.
+func parseExpr(source string) (expr, error) {
+	tokens, err := tokenizeExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &exprParser{tokens: tokens}
+	result, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := parser.peek(); token.kind != "end" {
+		return nil, fmt.Errorf("unexpected '%v' at position %v", token.text, token.start+1)
+	}
+	return result, nil
+}
+
+func (parser *exprParser) peek() exprToken {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *exprParser) next() exprToken {
+	token := parser.tokens[parser.pos]
+	if token.kind != "end" {
+		parser.pos++
+	}
+	return token
+}
+
+// acceptOp consumes the next token if it is one of ops
+func (parser *exprParser) acceptOp(ops ...string) (string, bool) {
+	token := parser.peek()
+	if token.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if token.text == op {
+			parser.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// parseBinary parses a left associative chain of ops between operands
+func (parser *exprParser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := parser.acceptOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op, left, right}
+	}
+}
+
+func (parser *exprParser) parseOr() (expr, error) {
+	return parser.parseBinary(parser.parseAnd, "||")
+}
+
+func (parser *exprParser) parseAnd() (expr, error) {
+	return parser.parseBinary(parser.parseComparison, "&&")
+}
+
+func (parser *exprParser) parseComparison() (expr, error) {
+	left, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := parser.acceptOp("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op, left, right}, nil
+}
+
+func (parser *exprParser) parseSum() (expr, error) {
+	return parser.parseBinary(parser.parseProduct, "+", "-")
+}
+
+func (parser *exprParser) parseProduct() (expr, error) {
+	return parser.parseBinary(parser.parseUnary, "*", "/", "%")
+}
+
+func (parser *exprParser) parseUnary() (expr, error) {
+	if op, ok := parser.acceptOp("!", "-"); ok {
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op, operand}, nil
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *exprParser) parsePrimary() (expr, error) {
+	token := parser.next()
+	switch token.kind {
+	case "number":
+		value, ok := new(big.Rat).SetString(token.text)
+		if !ok {
+			return nil, fmt.Errorf("invalid number '%v' at position %v", token.text, token.start+1)
+		}
+		return &literalExpr{numberValue(value)}, nil
+	case "hex":
+		digits := token.text[2:]
+		if len(digits)%2 == 1 {
+			digits = "0" + digits
+		}
+		value, err := hex.DecodeString(digits)
+		if err != nil || len(digits) == 0 {
+			return nil, fmt.Errorf("invalid hex value '%v' at position %v", token.text, token.start+1)
+		}
+		return &literalExpr{bytesValue(value)}, nil
+	case "string":
+		return &literalExpr{stringValue(token.text)}, nil
+	case "ident":
+		if token.text == "true" || token.text == "false" {
+			return &literalExpr{boolValue(token.text == "true")}, nil
+		}
+		if _, ok := parser.acceptOp("("); ok {
+			if _, ok := exprFunctions[token.text]; !ok {
+				return nil, fmt.Errorf("unknown function '%v' at position %v", token.text, token.start+1)
+			}
+			call := &callExpr{name: token.text}
+			if _, ok := parser.acceptOp(")"); ok {
+				return call, nil
+			}
+			for {
+				arg, err := parser.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if _, ok := parser.acceptOp(")"); ok {
+					return call, nil
+				}
+				if _, ok := parser.acceptOp(","); !ok {
+					next := parser.peek()
+					return nil, fmt.Errorf("expected ',' or ')' at position %v", next.start+1)
+				}
+			}
+		}
+		_, isField := orderFields[token.text]
+		_, isDerived := derivedValues[token.text]
+		_, isRecord := recordValues[token.text]
+		if !isField && !isDerived && !isRecord {
+			return nil, fmt.Errorf("unknown field '%v' at position %v", token.text, token.start+1)
+		}
+		return &identExpr{token.text}, nil
+	case "op":
+		if token.text == "(" {
+			inner, err := parser.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := parser.acceptOp(")"); !ok {
+				next := parser.peek()
+				return nil, fmt.Errorf("expected ')' at position %v", next.start+1)
+			}
+			return inner, nil
+		}
+	case "end":
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%v' at position %v", token.text, token.start+1)
+}
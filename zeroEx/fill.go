@@ -0,0 +1,242 @@
+package zeroEx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+)
+
+type fill struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	amount         string
+	total          bool
+	fillOrKill     bool
+	keyOpts        utils.KeyOptions
+	txOpts         transactionOptions
+}
+
+func (p *fill) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *fill) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*fill) Name() string     { return "fill" }
+func (*fill) Synopsis() string { return "Fill orders on-chain as the taker" }
+func (*fill) Usage() string {
+	return `msv 0x fill [--amount AMOUNT [--total]] [--fill-or-kill] [--dry-run] [--password-file FILE | --password-env VAR] [--gas-price WEI | --gas-price-strategy STRATEGY] [--gas-limit GAS] [--max-gas-price WEI] [--chain-id ID] [--input FILE] [--output FILE] ETHEREUM_RPC_URL KEY_FILE:
+  Fill each order on the exchange contract using the specified key as the
+  taker. By default the remaining available amount of each order is filled.
+  If --amount is provided, at most AMOUNT of the taker token will be filled
+  per order, or across all orders if --total is also provided.
+
+  For each order a result record is written to the output, containing the
+  order along with the transaction hash, the taker token amount filled, the
+  gas used, and the reason the fill failed, if it did. With --dry-run, the
+  fills are estimated but not sent, and each record instead contains the
+  amount that would be filled, the calldata, estimated gas and cost.
+
+  KEY_FILE may be a plaintext private key or an encrypted geth keystore file.
+`
+}
+
+func (p *fill) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.amount, "amount", "", "The taker token amount to fill, in base units")
+	f.BoolVar(&p.total, "total", false, "Treat --amount as the total to fill across all orders")
+	f.BoolVar(&p.fillOrKill, "fill-or-kill", false, "Use fillOrKillOrder, failing if the full amount cannot be filled")
+	p.keyOpts.SetFlags(f)
+	p.txOpts.SetFlags(f)
+}
+
+func (p *fill) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var amount *big.Int
+	if p.amount != "" {
+		var ok bool
+		amount, ok = new(big.Int).SetString(p.amount, 10)
+		if !ok {
+			log.Printf("Error processing amount: %v", p.amount)
+			return subcommands.ExitFailure
+		}
+	} else if p.total {
+		log.Printf("--total requires --amount")
+		return subcommands.ExitUsageError
+	}
+	client, err := rpc.Dial(f.Arg(0))
+	if err != nil {
+		log.Printf("Error establishing Ethereum connection: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	conn := ethclient.NewClient(client)
+	privKey, err := p.keyOpts.LoadKey(f.Arg(1))
+	if err != nil {
+		log.Printf("Error loading key: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	sender, err := p.txOpts.Sender(client, conn)
+	if err != nil {
+		log.Printf("Error configuring transactions: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return FillMain(p.inputFile, p.outputFile, conn, sender, privKey, amount, p.total, p.fillOrKill)
+}
+
+// fillResult is the outcome of filling a single order
+type fillResult struct {
+	txHash  string
+	filled  *big.Int
+	gasUsed *big.Int
+	err     string
+	dryRun  map[string]interface{}
+}
+
+func (result *fillResult) annotations() map[string]interface{} {
+	if result.dryRun != nil {
+		annotations := result.dryRun
+		annotations["fillTakerTokenAmount"] = result.filled.String()
+		return annotations
+	}
+	annotations := map[string]interface{}{
+		"txHash":                 result.txHash,
+		"filledTakerTokenAmount": result.filled.String(),
+		"gasUsed":                "0",
+	}
+	if result.gasUsed != nil {
+		annotations["gasUsed"] = result.gasUsed.String()
+	}
+	if result.err != "" {
+		annotations["error"] = result.err
+	}
+	return annotations
+}
+
+// remainingTakerAmount returns the taker token amount of the order that has
+// not yet been filled or cancelled.
+func remainingTakerAmount(exchange *exchangecontract.Exchange, order *types.Order) (*big.Int, error) {
+	unavailable, err := exchange.GetUnavailableTakerTokenAmount(nil, orderHash(order))
+	if err != nil {
+		return nil, err
+	}
+	remaining := new(big.Int).Sub(new(big.Int).SetBytes(order.TakerTokenAmount[:]), unavailable)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	return remaining, nil
+}
+
+func fillOrder(conn *ethclient.Client, sender *TransactionSender, key *ecdsa.PrivateKey, order *types.Order, amount *big.Int, fillOrKill bool) *fillResult {
+	result := &fillResult{filled: new(big.Int)}
+	exchange, err := exchangecontract.NewExchange(orCommon.ToGethAddress(order.ExchangeAddress), conn)
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+	remaining, err := remainingTakerAmount(exchange, order)
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+	if amount == nil || amount.Cmp(remaining) > 0 {
+		amount = remaining
+	}
+	if amount.Sign() == 0 {
+		result.err = "nothing to fill"
+		return result
+	}
+	transaction, receipt, err := sender.TransactAndWait(key, func(opts *bind.TransactOpts) (*gethTypes.Transaction, error) {
+		if fillOrKill {
+			return exchange.FillOrKillOrder(opts, orderAddresses(order), orderValues(order), amount, order.Signature.V, order.Signature.R, order.Signature.S)
+		}
+		return exchange.FillOrder(opts, orderAddresses(order), orderValues(order), amount, false, order.Signature.V, order.Signature.R, order.Signature.S)
+	})
+	if err != nil {
+		result.err = err.Error()
+		if transaction != nil {
+			result.txHash = transaction.Hash().Hex()
+		}
+		return result
+	}
+	if sender.DryRun {
+		result.filled = amount
+		result.dryRun = dryRunAnnotations(transaction)
+		return result
+	}
+	return receiptFillResult(receipt, order)
+}
+
+// receiptFillResult reports how much of the order a mined transaction filled,
+// or why it did not fill the order.
+func receiptFillResult(receipt *gethTypes.Receipt, order *types.Order) *fillResult {
+	result := &fillResult{
+		txHash:  receipt.TxHash.Hex(),
+		filled:  new(big.Int),
+		gasUsed: receipt.GasUsed,
+	}
+	logs := parseExchangeLogs(receipt)
+	hash := orderHash(order)
+	if filled, ok := logs.filled[hash]; ok {
+		result.filled = filled
+	} else if reason, ok := logs.errors[hash]; ok {
+		result.err = reason
+	} else {
+		result.err = "transaction failed"
+	}
+	return result
+}
+
+func FillMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, sender *TransactionSender, key *ecdsa.PrivateKey, amount *big.Int, total, fillOrKill bool) subcommands.ExitStatus {
+	var remainingTotal *big.Int
+	if total {
+		remainingTotal = new(big.Int).Set(amount)
+	}
+	failed := 0
+	for order := range orderScanner(inputFile) {
+		var result *fillResult
+		if total && remainingTotal.Sign() == 0 {
+			result = &fillResult{filled: new(big.Int), err: "total amount already filled"}
+		} else if total {
+			result = fillOrder(conn, sender, key, order, remainingTotal, fillOrKill)
+			remainingTotal.Sub(remainingTotal, result.filled)
+		} else {
+			result = fillOrder(conn, sender, key, order, amount, fillOrKill)
+		}
+		if result.err != "" {
+			failed++
+			log.Printf("Error filling order %#x: %v", order.Hash(), result.err)
+		}
+		if err := writeAnnotatedOrder(order, result.annotations(), outputFile); err != nil {
+			log.Printf("Error writing result: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	sender.LogDryRunCost()
+	if failed > 0 {
+		log.Printf("Failed to fill %v orders", failed)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,123 @@
+package zeroEx
+
+import (
+	"context"
+	"flag"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+type filter struct {
+	inputFileName  string
+	outputFileName string
+	rejectFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+}
+
+func (p *filter) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *filter) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*filter) Name() string     { return "filter" }
+func (*filter) Synopsis() string { return "Keep the orders that match an expression" }
+func (*filter) Usage() string {
+	return `msv 0x filter [--reject FILE] [--input FILE] [--output FILE] EXPRESSION:
+  Write the orders for which EXPRESSION is true to the output. Orders that
+  don't match are written to the reject file, or dropped if no reject file is
+  specified. For example:
+
+    msv 0x filter 'makerTokenAmount > 10e18 && expirationUnixTimestampSec > now()'
+
+  Expressions can use every order field, named as in order records:
+  maker, taker, makerTokenAddress, takerTokenAddress, feeRecipient,
+  exchangeContractAddress, makerTokenAmount, takerTokenAmount, makerFee,
+  takerFee, expirationUnixTimestampSec, salt, takerTokenAmountFilled,
+  takerTokenAmountCancelled, and the signature's v, r and s. They can also
+  use these derived values:
+
+    price     taker token amount per maker token amount, in base units
+    hash      the order hash
+    expired   whether the order's expiration has passed
+    isSigned  whether the order has a valid signature from its maker
+
+  Records annotated by "msv 0x status" also have a state, which can be
+  compared with quoted text, as in 'state == "open"'.
+
+  Numbers are exact, and may be written as 1.5 or 10e18. Addresses and hashes
+  are written in hex, and text in single or double quotes. The operators are
+  + - * / % == != < <= > >= && || and !, and the functions are now(),
+  floor(x) and ceil(x).
+`
+}
+
+func (p *filter) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.rejectFileName, "reject", "", "File for orders that don't match")
+}
+
+func (p *filter) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var rejectFile io.Writer = ioutil.Discard
+	if p.rejectFileName != "" {
+		file, err := utils.OpenOutput(p.rejectFileName)
+		if err != nil {
+			log.Printf("Error opening reject file: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+		defer file.Close()
+		rejectFile = file
+	}
+	return FilterMain(p.inputFile, p.outputFile, rejectFile, f.Arg(0))
+}
+
+// FilterMain writes the orders matching expression to outputFile, and the rest
+// to rejectFile
+func FilterMain(inputFile io.Reader, outputFile, rejectFile io.Writer, expression string) subcommands.ExitStatus {
+	parsed, err := parseExpr(expression)
+	if err != nil {
+		log.Printf("Error parsing expression: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	matched, rejected := 0, 0
+	for record := range recordScanner(inputFile) {
+		order := record.order
+		result, err := evalRecord(parsed, record)
+		if err != nil {
+			log.Printf("Error evaluating expression for order %#x: %v", order.Hash(), err.Error())
+			return subcommands.ExitFailure
+		}
+		if result.kind != exprBool {
+			log.Printf("Expression must be true or false, not a %v", result.kind)
+			return subcommands.ExitFailure
+		}
+		// The whole input record is written, so annotations such as those
+		// added by status reach the next command
+		destination := rejectFile
+		if result.boolean {
+			matched++
+			destination = outputFile
+		} else {
+			rejected++
+		}
+		if err := writeAnnotatedOrder(order, record.fields, destination); err != nil {
+			log.Printf("Error writing order: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	log.Printf("%v orders matched, %v rejected", matched, rejected)
+	return subcommands.ExitSuccess
+}
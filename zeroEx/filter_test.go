@@ -0,0 +1,121 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func filterTestOrders(t *testing.T) *bytes.Buffer {
+	inputBuffer := &bytes.Buffer{}
+	for i, amount := range []int64{5, 20, 30} {
+		order := &types.Order{}
+		order.Initialize()
+		order.Maker[19] = byte(i + 1)
+		copy(order.MakerTokenAmount[:], abi.U256(new(big.Int).Mul(big.NewInt(amount), big.NewInt(1e18))))
+		copy(order.TakerTokenAmount[:], abi.U256(big.NewInt(amount)))
+		copy(order.ExpirationTimestampInSec[:], abi.U256(big.NewInt(time.Now().Unix()+3600*(2*int64(i)-1))))
+		data, err := json.Marshal(order)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		inputBuffer.Write(append(data, '\n'))
+	}
+	return inputBuffer
+}
+
+func filterMakers(t *testing.T, buffer *bytes.Buffer) []byte {
+	makers := []byte{}
+	scanner := bufio.NewScanner(buffer)
+	for scanner.Scan() {
+		order := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		makers = append(makers, order.Maker[19])
+	}
+	return makers
+}
+
+func TestFilterExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		matched    []byte
+		rejected   []byte
+	}{
+		// The first order expired an hour ago
+		{"makerTokenAmount > 10e18 && expirationUnixTimestampSec > now()", []byte{2, 3}, []byte{1}},
+		{"!expired && (makerTokenAmount / 1e18) % 3 == 0", []byte{3}, []byte{1, 2}},
+		{"maker == 0x0000000000000000000000000000000000000002 || takerTokenAmount * 1e18 == makerTokenAmount * 2", []byte{2}, []byte{1, 3}},
+		{"price == 1 / 1e18 && !isSigned", []byte{1, 2, 3}, []byte{}},
+	}
+	for _, test := range tests {
+		outputBuffer := &bytes.Buffer{}
+		rejectBuffer := &bytes.Buffer{}
+		if status := zeroEx.FilterMain(filterTestOrders(t), outputBuffer, rejectBuffer, test.expression); status != subcommands.ExitSuccess {
+			t.Fatalf("%v: bad exitcode: %v", test.expression, status)
+		}
+		if matched := filterMakers(t, outputBuffer); !bytes.Equal(matched, test.matched) {
+			t.Errorf("%v: matched %v, expected %v", test.expression, matched, test.matched)
+		}
+		if rejected := filterMakers(t, rejectBuffer); !bytes.Equal(rejected, test.rejected) {
+			t.Errorf("%v: rejected %v, expected %v", test.expression, rejected, test.rejected)
+		}
+	}
+}
+
+func TestFilterInvalidExpression(t *testing.T) {
+	for _, expression := range []string{"makerTokenAmount >", "unknownField == 1", "makerTokenAmount + 1", "maker > 1"} {
+		if status := zeroEx.FilterMain(filterTestOrders(t), &bytes.Buffer{}, &bytes.Buffer{}, expression); status != subcommands.ExitFailure {
+			t.Errorf("%v: expected failure, got %v", expression, status)
+		}
+	}
+}
+
+func TestFilterStatusRecords(t *testing.T) {
+	inputBuffer := &bytes.Buffer{}
+	for i, state := range []string{zeroEx.StateOpen, zeroEx.StateFilled, zeroEx.StatePartiallyFilled} {
+		order := &types.Order{}
+		order.Initialize()
+		order.Maker[19] = byte(i + 1)
+		data, _ := json.Marshal(order)
+		record := make(map[string]interface{})
+		json.Unmarshal(data, &record)
+		record["state"] = state
+		record["takerTokenAmountFilled"] = fmt.Sprintf("%v", i)
+		record["takerTokenAmountCancelled"] = "0"
+		data, _ = json.Marshal(record)
+		inputBuffer.Write(append(data, '\n'))
+	}
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.FilterMain(inputBuffer, outputBuffer, &bytes.Buffer{}, `state != "filled" && takerTokenAmountFilled < 5`); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	states := []string{}
+	for scanner.Scan() {
+		record := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err.Error())
+		}
+		states = append(states, fmt.Sprintf("%v", record["state"]))
+		if _, ok := record["takerTokenAmountFilled"]; !ok {
+			t.Errorf("Expected takerTokenAmountFilled to be kept")
+		}
+	}
+	if len(states) != 2 || states[0] != zeroEx.StateOpen || states[1] != zeroEx.StatePartiallyFilled {
+		t.Errorf("Expected open and partiallyFilled orders, got %v", states)
+	}
+	// Records without a state can't be filtered on it
+	if status := zeroEx.FilterMain(filterTestOrders(t), &bytes.Buffer{}, &bytes.Buffer{}, `state == "open"`); status != subcommands.ExitFailure {
+		t.Errorf("Expected records without a state to fail, got %v", status)
+	}
+}
@@ -0,0 +1,251 @@
+package zeroEx
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The strategies for choosing a transaction's gas price
+const (
+	GasPriceFixed      = "fixed"
+	GasPriceNode       = "node"
+	GasPricePercentile = "percentile"
+)
+
+// transactionOptions holds the flags shared by commands that send
+// transactions.
+type transactionOptions struct {
+	gasPrice    string
+	gasLimit    uint64
+	maxGasPrice string
+	strategy    string
+	percentile  int
+	blocks      int
+	chainID     uint64
+	dryRun      bool
+}
+
+func (opts *transactionOptions) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&opts.gasPrice, "gas-price", "", "The gas price for transactions, in wei. Implies --gas-price-strategy=fixed")
+	f.Uint64Var(&opts.gasLimit, "gas-limit", 0, "The gas limit for transactions [estimated]")
+	f.StringVar(&opts.maxGasPrice, "max-gas-price", "", "The highest gas price to pay, in wei")
+	f.StringVar(&opts.strategy, "gas-price-strategy", "", "How to choose gas prices: fixed, node, or percentile [node]")
+	f.IntVar(&opts.percentile, "gas-price-percentile", 50, "With --gas-price-strategy=percentile, the percentile of recent transactions' gas prices to use")
+	f.IntVar(&opts.blocks, "gas-price-blocks", 20, "With --gas-price-strategy=percentile, the number of recent blocks to sample")
+	f.BoolVar(&opts.dryRun, "dry-run", false, "Estimate the gas and cost of each transaction without sending anything")
+	f.Uint64Var(&opts.chainID, "chain-id", 0, "The chain ID to sign transactions for. The command will not run if the node is on a different chain [the node's chain ID]")
+}
+
+// methodNotSupported reports whether a node rejected a request because it
+// doesn't implement the method
+func methodNotSupported(err error) bool {
+	if rpcErr, ok := err.(rpc.Error); ok && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "does not exist") || strings.Contains(message, "not found") || strings.Contains(message, "not supported")
+}
+
+// ChainID returns the chain ID transactions should be signed for, as reported
+// by the node's eth_chainId. The vendored ethclient has no way to request it,
+// so it is requested through the underlying RPC client. If expected is not
+// zero, it must match the node's chain ID. Nodes that predate eth_chainId
+// fall back to expected, or to their network ID, which matches the chain ID
+// on mainnet and the public testnets but not on every chain.
+func ChainID(client *rpc.Client, expected uint64) (*big.Int, error) {
+	var chainID hexutil.Big
+	err := client.CallContext(context.Background(), &chainID, "eth_chainId")
+	if err == nil {
+		if expected != 0 && new(big.Int).SetUint64(expected).Cmp(chainID.ToInt()) != 0 {
+			return nil, fmt.Errorf("--chain-id %v does not match the node's chain ID %v", expected, chainID.ToInt())
+		}
+		return chainID.ToInt(), nil
+	}
+	if !methodNotSupported(err) {
+		return nil, err
+	}
+	if expected != 0 {
+		log.Printf("Warning: the node does not support eth_chainId, so --chain-id %v cannot be checked", expected)
+		return new(big.Int).SetUint64(expected), nil
+	}
+	var version string
+	if err := client.CallContext(context.Background(), &version, "net_version"); err != nil {
+		return nil, err
+	}
+	networkID, ok := new(big.Int).SetString(version, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid network ID: '%v'", version)
+	}
+	log.Printf("Warning: the node does not support eth_chainId, using its network ID %v as the chain ID. Set --chain-id if the chain ID differs", networkID)
+	return networkID, nil
+}
+
+// Sender returns a TransactionSender configured by the flags. client is the
+// RPC client conn was created with.
+func (opts *transactionOptions) Sender(client *rpc.Client, conn *ethclient.Client) (*TransactionSender, error) {
+	chainID, err := ChainID(client, opts.chainID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Signing transactions for chain ID %v", chainID)
+	gasPricer, err := opts.GasPricer(conn)
+	if err != nil {
+		return nil, err
+	}
+	sender := NewTransactionSender(conn, chainID)
+	sender.GasPricer = gasPricer
+	sender.DryRun = opts.dryRun
+	if opts.gasLimit > 0 {
+		sender.GasLimit = new(big.Int).SetUint64(opts.gasLimit)
+	}
+	return sender, nil
+}
+
+// GasPricer returns the GasPricer described by the flags
+func (opts *transactionOptions) GasPricer(conn *ethclient.Client) (GasPricer, error) {
+	strategy := opts.strategy
+	if strategy == "" {
+		strategy = GasPriceNode
+		if opts.gasPrice != "" {
+			strategy = GasPriceFixed
+		}
+	}
+	var pricer GasPricer
+	switch strategy {
+	case GasPriceFixed:
+		if opts.gasPrice == "" {
+			return nil, errors.New("--gas-price-strategy=fixed requires --gas-price")
+		}
+		gasPrice, ok := new(big.Int).SetString(opts.gasPrice, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid gas price: %v", opts.gasPrice)
+		}
+		pricer = &fixedGasPricer{gasPrice}
+	case GasPriceNode:
+		pricer = &nodeGasPricer{conn}
+	case GasPricePercentile:
+		if opts.percentile < 0 || opts.percentile > 100 {
+			return nil, fmt.Errorf("invalid gas price percentile: %v", opts.percentile)
+		}
+		if opts.blocks < 1 {
+			return nil, errors.New("--gas-price-blocks must be at least 1")
+		}
+		pricer = &percentileGasPricer{conn: conn, percentile: opts.percentile, blocks: opts.blocks}
+	default:
+		return nil, fmt.Errorf("unknown gas price strategy: %v", strategy)
+	}
+	if strategy != GasPriceFixed && opts.gasPrice != "" {
+		return nil, fmt.Errorf("--gas-price cannot be used with --gas-price-strategy=%v", strategy)
+	}
+	if opts.maxGasPrice != "" {
+		maxGasPrice, ok := new(big.Int).SetString(opts.maxGasPrice, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid max gas price: %v", opts.maxGasPrice)
+		}
+		pricer = &cappedGasPricer{pricer, maxGasPrice}
+	}
+	return pricer, nil
+}
+
+// GasPricer chooses the gas price for a transaction
+type GasPricer interface {
+	GasPrice() (*big.Int, error)
+}
+
+type fixedGasPricer struct {
+	gasPrice *big.Int
+}
+
+func (pricer *fixedGasPricer) GasPrice() (*big.Int, error) {
+	return pricer.gasPrice, nil
+}
+
+type nodeGasPricer struct {
+	conn TransactionBackend
+}
+
+func (pricer *nodeGasPricer) GasPrice() (*big.Int, error) {
+	return pricer.conn.SuggestGasPrice(context.Background())
+}
+
+// percentileGasPricer uses a percentile of the gas prices paid by the
+// transactions in recent blocks. The price is cached until a new block is
+// mined, so sending several transactions doesn't download the blocks again
+// for each one.
+type percentileGasPricer struct {
+	conn       *ethclient.Client
+	percentile int
+	blocks     int
+	head       *big.Int
+	gasPrice   *big.Int
+	mutex      sync.Mutex
+}
+
+func (pricer *percentileGasPricer) GasPrice() (*big.Int, error) {
+	header, err := pricer.conn.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	pricer.mutex.Lock()
+	defer pricer.mutex.Unlock()
+	if pricer.head != nil && pricer.head.Cmp(header.Number) == 0 {
+		return pricer.gasPrice, nil
+	}
+	gasPrice, err := pricer.blockGasPrice(header.Number)
+	if err != nil {
+		return nil, err
+	}
+	pricer.head, pricer.gasPrice = header.Number, gasPrice
+	return gasPrice, nil
+}
+
+// blockGasPrice calculates the percentile gas price from the blocks up to
+// head
+func (pricer *percentileGasPricer) blockGasPrice(head *big.Int) (*big.Int, error) {
+	prices := []*big.Int{}
+	number := new(big.Int).Set(head)
+	for i := 0; i < pricer.blocks && number.Sign() >= 0; i++ {
+		block, err := pricer.conn.BlockByNumber(context.Background(), number)
+		if err != nil {
+			return nil, err
+		}
+		for _, transaction := range block.Transactions() {
+			prices = append(prices, transaction.GasPrice())
+		}
+		number = new(big.Int).Sub(number, big.NewInt(1))
+	}
+	if len(prices) == 0 {
+		log.Printf("No transactions in the last %v blocks, using the node's suggested gas price", pricer.blocks)
+		return pricer.conn.SuggestGasPrice(context.Background())
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	return prices[(len(prices)-1)*pricer.percentile/100], nil
+}
+
+// cappedGasPricer limits the gas price another GasPricer chooses
+type cappedGasPricer struct {
+	pricer      GasPricer
+	maxGasPrice *big.Int
+}
+
+func (pricer *cappedGasPricer) GasPrice() (*big.Int, error) {
+	gasPrice, err := pricer.pricer.GasPrice()
+	if err != nil {
+		return nil, err
+	}
+	if gasPrice.Cmp(pricer.maxGasPrice) > 0 {
+		log.Printf("Gas price %v is above the maximum, using %v", gasPrice, pricer.maxGasPrice)
+		return pricer.maxGasPrice, nil
+	}
+	return gasPrice, nil
+}
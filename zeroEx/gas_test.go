@@ -0,0 +1,69 @@
+package zeroEx_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/notegio/massive/zeroEx"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// chainServer is a JSON-RPC server answering eth_chainId and net_version. An
+// empty chainID means eth_chainId is not supported.
+func chainServer(chainID, networkID string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case request.Method == "eth_chainId" && chainID != "":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"%v"}`, request.ID, chainID)
+		case request.Method == "net_version":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"%v"}`, request.ID, networkID)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"the method %v does not exist/is not available"}}`, request.ID, request.Method)
+		}
+	}))
+}
+
+func TestChainID(t *testing.T) {
+	items := []struct {
+		name      string
+		chainID   string
+		networkID string
+		flag      uint64
+		expected  int64
+		err       bool
+	}{
+		{"Ethereum Classic", "0x3d", "1", 0, 61, false},
+		{"matching flag", "0x3d", "1", 61, 61, false},
+		{"network ID as flag", "0x3d", "1", 1, 0, true},
+		{"no eth_chainId", "", "3", 0, 3, false},
+		{"no eth_chainId with flag", "", "1", 61, 61, false},
+	}
+	for _, item := range items {
+		server := chainServer(item.chainID, item.networkID)
+		client, err := rpc.Dial(server.URL)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		chainID, err := zeroEx.ChainID(client, item.flag)
+		server.Close()
+		if item.err {
+			if err == nil {
+				t.Errorf("%v: expected an error, got chain ID %v", item.name, chainID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", item.name, err.Error())
+		} else if chainID.Int64() != item.expected {
+			t.Errorf("%v: expected chain ID %v, got %v", item.name, item.expected, chainID)
+		}
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 type getFees struct {
@@ -26,6 +28,10 @@ type getFees struct {
 	inputFileName  string
 	outputFileName string
 	makerShare     float64
+	concurrency    int
+	cacheTTL       time.Duration
+	localConfig    string
+	timeout        time.Duration
 	inputFile      *os.File
 	outputFile     *os.File
 }
@@ -41,94 +47,381 @@ func (p *getFees) SetIOFiles(inputFile, outputFile *os.File) {
 func (*getFees) Name() string     { return "getFees" }
 func (*getFees) Synopsis() string { return "Set fees on incoming orders" }
 func (*getFees) Usage() string {
-	return `msv 0x getFees [--target RELAYER_URL] [--maker-share 1] [--input FILE] [--output FILE]:
-  Get fees from the target relayer and set them on the order
+	return `msv 0x getFees [--target RELAYER_URL] [--maker-share 1] [--concurrency N] [--cache-ttl DURATION] [--timeout DURATION] [--local CONFIG_FILE] [--input FILE] [--output FILE]:
+  Get fees from the target relayer and set them on the order. The relayer is
+  sent the full Standard Relayer API fee request for each order.
+
+  Fee quotes are cached by their request, so orders that would send the
+  relayer the same fee request apart from the salt share a single quote.
+  --cache-ttl limits how
+  long a quote is reused. Up to --concurrency quotes are requested at once,
+  and orders are written in the same order they were read.
+
+  With --local, fees are calculated the way openrelay does instead of being
+  requested from a relayer. CONFIG_FILE is a JSON file with the base fee,
+  each fee recipient's fee as a percentage of the base fee, and maker account
+  discounts as a percentage of the base fee:
+
+    {
+      "baseFee": "100000000000000000",
+      "defaultFeeRecipient": "0xc22d5b2951db72b44cfb8089bb8cd374a3c354ea",
+      "affiliates": {"0xc22d5b2951db72b44cfb8089bb8cd374a3c354ea": {"feePercent": 100}},
+      "accounts": {"0x324454186bb728a3ea55750e0618ff1b18ce6cf8": {"discountPercent": 50, "expiration": 1600000000}}
+    }
+
+  Orders without a fee recipient use defaultFeeRecipient, and orders whose fee
+  recipient is not an affiliate are an error.
 `
 }
 
 func (p *getFees) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.targetURL, "target", "https://api.openrelay.xyz", "Set the target 0x relayer")
 	f.Float64Var(&p.makerShare, "maker-share", -1, "What share of fees")
+	f.IntVar(&p.concurrency, "concurrency", 1, "The number of fee quotes to request at once")
+	f.DurationVar(&p.cacheTTL, "cache-ttl", 0, "How long to reuse a fee quote [forever]")
+	f.DurationVar(&p.timeout, "timeout", 30*time.Second, "The timeout for each fee request")
+	f.StringVar(&p.localConfig, "local", "", "Calculate fees from a config file instead of a relayer")
 	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
 	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
 }
 
 func (p *getFees) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	if f.NArg() != 0 {
+	if f.NArg() != 0 || p.concurrency < 1 {
 		os.Stderr.WriteString(p.Usage())
 		return subcommands.ExitUsageError
 	}
 	utils.SetIO(p)
-	return GetFeesMain(p.targetURL, p.inputFile, p.outputFile, p.makerShare)
-}
-
-func GetFeesMain(targetURL string, inputFile io.Reader, outputFile io.Writer, makerShare float64) subcommands.ExitStatus {
-	targetURL = strings.TrimSuffix(targetURL, "/")
-	channel := orderScanner(inputFile)
-	for order := range channel {
-		feeInput := &ingest.FeeInputPayload{}
-		feeInput.Maker = order.Maker.String()
-		emptyAddress := &types.Address{}
-		if bytes.Equal(order.FeeRecipient[:], emptyAddress[:]) {
-			feeInput.FeeRecipient = ""
-		} else {
-			feeInput.FeeRecipient = order.FeeRecipient.String()
-		}
-		data, err := json.Marshal(feeInput)
-		if err != nil {
-			log.Printf("Error serializing order: %v", err.Error())
-			return subcommands.ExitFailure
-		}
-		resp, err := http.Post(fmt.Sprintf("%v/v0/fees", targetURL), "application/json", bytes.NewReader(data))
+	quoter := NewFeeQuoter(&http.Client{Timeout: p.timeout}, p.targetURL, p.cacheTTL)
+	if p.localConfig != "" {
+		source, err := LoadLocalFeeSource(p.localConfig)
 		if err != nil {
-			log.Printf("Getting fees from %v: %v", targetURL, err.Error())
+			log.Printf("Error loading fee config: %v", err.Error())
 			return subcommands.ExitFailure
 		}
-		feeBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error getting response body: %v", err.Error())
-			return subcommands.ExitFailure
-		}
-		if resp.StatusCode != 200 {
-			log.Printf("Got unexpected status code: %v", resp.StatusCode)
-			log.Printf("Body: %v", string(feeBytes))
-			return subcommands.ExitFailure
-		}
-		fees := &ingest.FeeResponse{}
-		if err := json.Unmarshal(feeBytes, fees); err != nil {
-			log.Printf("Error parsing response body: %v - '%v'", err.Error(), string(feeBytes))
-			return subcommands.ExitFailure
+		quoter = NewFeeSourceQuoter(source, p.cacheTTL)
+	}
+	return GetFeesQuoterMain(quoter, p.inputFile, p.outputFile, p.makerShare, p.concurrency)
+}
+
+// requestFees asks the relayer at targetURL for the fees it charges on order
+func requestFees(client *http.Client, targetURL string, order *types.Order) (*ingest.FeeResponse, error) {
+	data, err := feeRequest(order)
+	if err != nil {
+		return nil, err
+	}
+	return postFeeRequest(client, targetURL, data)
+}
+
+// feeRequestPayload is the body of a Standard Relayer API v0 fee request.
+// The feeRecipient isn't part of the standard, but openrelay uses it to look
+// up the affiliate whose fees apply.
+type feeRequestPayload struct {
+	ExchangeContractAddress    string `json:"exchangeContractAddress"`
+	Maker                      string `json:"maker"`
+	Taker                      string `json:"taker"`
+	MakerTokenAddress          string `json:"makerTokenAddress"`
+	TakerTokenAddress          string `json:"takerTokenAddress"`
+	FeeRecipient               string `json:"feeRecipient,omitempty"`
+	MakerTokenAmount           string `json:"makerTokenAmount"`
+	TakerTokenAmount           string `json:"takerTokenAmount"`
+	ExpirationUnixTimestampSec string `json:"expirationUnixTimestampSec"`
+	Salt                       string `json:"salt"`
+}
+
+// feePayload returns the fee request for order
+func feePayload(order *types.Order) *feeRequestPayload {
+	uint256 := func(value *types.Uint256) string { return new(big.Int).SetBytes(value[:]).String() }
+	feeInput := &feeRequestPayload{
+		ExchangeContractAddress:    order.ExchangeAddress.String(),
+		Maker:                      order.Maker.String(),
+		Taker:                      order.Taker.String(),
+		MakerTokenAddress:          order.MakerToken.String(),
+		TakerTokenAddress:          order.TakerToken.String(),
+		MakerTokenAmount:           uint256(order.MakerTokenAmount),
+		TakerTokenAmount:           uint256(order.TakerTokenAmount),
+		ExpirationUnixTimestampSec: uint256(order.ExpirationTimestampInSec),
+		Salt:                       uint256(order.Salt),
+	}
+	emptyAddress := &types.Address{}
+	if !bytes.Equal(order.FeeRecipient[:], emptyAddress[:]) {
+		feeInput.FeeRecipient = order.FeeRecipient.String()
+	}
+	return feeInput
+}
+
+// feeRequest returns the body of the fee request for order
+func feeRequest(order *types.Order) ([]byte, error) {
+	data, err := json.Marshal(feePayload(order))
+	if err != nil {
+		return nil, fmt.Errorf("serializing order: %v", err.Error())
+	}
+	return data, nil
+}
+
+// feeAmount parses a fee from a fee response, which must be a non-negative
+// base 10 integer that fits in a uint256
+func feeAmount(field, value string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || strings.ContainsAny(value, "+-") || amount.Cmp(maxUint256) > 0 {
+		return nil, fmt.Errorf("%v not a valid fee: '%v'", field, value)
+	}
+	return amount, nil
+}
+
+// feeAddress parses an address from a fee response, which must be a 0x
+// prefixed, 20 byte hex string
+func feeAddress(field, value string) (*types.Address, error) {
+	address := &types.Address{}
+	if len(value) != 2+2*len(address) || !strings.HasPrefix(value, "0x") {
+		return nil, fmt.Errorf("%v not a valid address: '%v'", field, value)
+	}
+	if _, err := hex.Decode(address[:], []byte(value[2:])); err != nil {
+		return nil, fmt.Errorf("%v not a valid hex string: %v", field, err.Error())
+	}
+	return address, nil
+}
+
+// checkFeeResponse checks that every field of a fee response is present and
+// well formed
+func checkFeeResponse(fees *ingest.FeeResponse) error {
+	if _, err := feeAmount("MakerFee", fees.MakerFee); err != nil {
+		return err
+	}
+	if _, err := feeAmount("TakerFee", fees.TakerFee); err != nil {
+		return err
+	}
+	if _, err := feeAddress("FeeRecipient", fees.FeeRecipient); err != nil {
+		return err
+	}
+	if _, err := feeAddress("TakerToSpecify", fees.TakerToSpecify); err != nil {
+		return err
+	}
+	return nil
+}
+
+// postFeeRequest sends a fee request to the relayer at targetURL
+func postFeeRequest(client *http.Client, targetURL string, data []byte) (*ingest.FeeResponse, error) {
+	resp, err := client.Post(fmt.Sprintf("%v/v0/fees", targetURL), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("getting fees from %v: %v", targetURL, err.Error())
+	}
+	defer resp.Body.Close()
+	feeBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("getting response body: %v", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code %v: %v", resp.StatusCode, string(feeBytes))
+	}
+	fees := &ingest.FeeResponse{}
+	if err := json.Unmarshal(feeBytes, fees); err != nil {
+		return nil, fmt.Errorf("parsing response body: %v - '%v'", err.Error(), string(feeBytes))
+	}
+	if err := checkFeeResponse(fees); err != nil {
+		return nil, fmt.Errorf("invalid fee response: %v - '%v'", err.Error(), string(feeBytes))
+	}
+	return fees, nil
+}
+
+// applyFees sets the fees, fee recipient and taker from a relayer's fee
+// response on order. If makerShare is in [0, 1), the total fee is split
+// between the maker and taker in that proportion.
+func applyFees(order *types.Order, fees *ingest.FeeResponse, makerShare float64) error {
+	makerFee, err := feeAmount("MakerFee", fees.MakerFee)
+	if err != nil {
+		return err
+	}
+	takerFee, err := feeAmount("TakerFee", fees.TakerFee)
+	if err != nil {
+		return err
+	}
+	feeRecipient, err := feeAddress("FeeRecipient", fees.FeeRecipient)
+	if err != nil {
+		return err
+	}
+	taker, err := feeAddress("TakerToSpecify", fees.TakerToSpecify)
+	if err != nil {
+		return err
+	}
+	if makerShare >= 0 && makerShare < 1 {
+		totalFee := new(big.Int).Add(makerFee, takerFee)
+		makerPercent := big.NewInt(int64(makerShare * 100))
+		makerFee = new(big.Int).Div(new(big.Int).Mul(totalFee, makerPercent), big.NewInt(100))
+		takerFee = new(big.Int).Sub(totalFee, makerFee)
+	}
+	copy(order.MakerFee[:], abi.U256(makerFee))
+	copy(order.TakerFee[:], abi.U256(takerFee))
+	copy(order.FeeRecipient[:], feeRecipient[:])
+	copy(order.Taker[:], taker[:])
+	return nil
+}
+
+// FeeSource calculates the fees for an order
+type FeeSource interface {
+	Fees(order *types.Order) (*ingest.FeeResponse, error)
+}
+
+// relayerFeeSource requests fees from a relayer
+type relayerFeeSource struct {
+	client    *http.Client
+	targetURL string
+}
+
+func (source *relayerFeeSource) Fees(order *types.Order) (*ingest.FeeResponse, error) {
+	return requestFees(source.client, source.targetURL, order)
+}
+
+// FeeQuoter gets fee quotes from a FeeSource, caching them by request. It is
+// safe for concurrent use, and concurrent requests for the same quote share a
+// single request to the source.
+type FeeQuoter struct {
+	source FeeSource
+	ttl    time.Duration
+	quotes map[string]*feeQuote
+	hits   int
+	misses int
+	mutex  sync.Mutex
+}
+
+// NewFeeQuoter creates a FeeQuoter for the relayer at targetURL, making
+// requests with client. Quotes are reused for ttl, or forever if ttl is zero.
+func NewFeeQuoter(client *http.Client, targetURL string, ttl time.Duration) *FeeQuoter {
+	return NewFeeSourceQuoter(&relayerFeeSource{client, strings.TrimSuffix(targetURL, "/")}, ttl)
+}
+
+// NewFeeSourceQuoter creates a FeeQuoter for any FeeSource
+func NewFeeSourceQuoter(source FeeSource, ttl time.Duration) *FeeQuoter {
+	return &FeeQuoter{
+		source: source,
+		ttl:    ttl,
+		quotes: make(map[string]*feeQuote),
+	}
+}
+
+type feeQuote struct {
+	fees    *ingest.FeeResponse
+	err     error
+	expires time.Time
+	channel chan bool
+}
+
+func (quote *feeQuote) Get() (*ingest.FeeResponse, error) {
+	for _ = range quote.channel {
+	}
+	return quote.fees, quote.err
+}
+
+// feeQuoteKey returns the cache key for order's fee quote. It is the fee
+// request without the salt, which is unique to each order and doesn't affect
+// the fees.
+func feeQuoteKey(order *types.Order) (string, error) {
+	payload := feePayload(order)
+	payload.Salt = ""
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("serializing order: %v", err.Error())
+	}
+	return string(data), nil
+}
+
+// Quote returns the source's fees for order
+func (quoter *FeeQuoter) Quote(order *types.Order) (*ingest.FeeResponse, error) {
+	key, err := feeQuoteKey(order)
+	if err != nil {
+		return nil, err
+	}
+	quoter.mutex.Lock()
+	quote, ok := quoter.quotes[key]
+	if ok && (quoter.ttl == 0 || time.Now().Before(quote.expires)) {
+		quoter.hits++
+		quoter.mutex.Unlock()
+		return quote.Get()
+	}
+	quoter.misses++
+	quote = &feeQuote{expires: time.Now().Add(quoter.ttl), channel: make(chan bool)}
+	quoter.quotes[key] = quote
+	quoter.mutex.Unlock()
+	quote.fees, quote.err = quoter.source.Fees(order)
+	if quote.err != nil {
+		// Don't cache failures, so later orders can try again
+		quoter.mutex.Lock()
+		if quoter.quotes[key] == quote {
+			delete(quoter.quotes, key)
 		}
-		makerFee, ok := new(big.Int).SetString(fees.MakerFee, 10)
-		if !ok {
-			log.Printf("MakerFee not a valid integer: '%v'", fees.MakerFee)
-			return subcommands.ExitFailure
+		quoter.mutex.Unlock()
+	}
+	close(quote.channel)
+	return quote.fees, quote.err
+}
+
+// Stats returns the number of quotes served from the cache and requested from
+// the relayer
+func (quoter *FeeQuoter) Stats() (hits, misses int) {
+	quoter.mutex.Lock()
+	defer quoter.mutex.Unlock()
+	return quoter.hits, quoter.misses
+}
+
+func GetFeesMain(targetURL string, inputFile io.Reader, outputFile io.Writer, makerShare float64) subcommands.ExitStatus {
+	return GetFeesQuoterMain(NewFeeQuoter(http.DefaultClient, targetURL, 0), inputFile, outputFile, makerShare, 1)
+}
+
+// GetFeesQuoterMain sets fees from quoter on each order, requesting up to
+// concurrency quotes at once. Orders are written in input order.
+func GetFeesQuoterMain(quoter *FeeQuoter, inputFile io.Reader, outputFile io.Writer, makerShare float64, concurrency int) subcommands.ExitStatus {
+	type feeResult struct {
+		order *types.Order
+		fees  *ingest.FeeResponse
+		err   error
+	}
+	// The buffer limits how many quotes are outstanding, while reading
+	// results in the order of the channel keeps the input order
+	results := make(chan chan *feeResult, concurrency-1)
+	// done is closed when an order fails, so no more quotes are requested and
+	// the rest of the input is read without being used
+	done := make(chan struct{})
+	go func() {
+		for order := range orderScanner(inputFile) {
+			select {
+			case <-done:
+				continue
+			default:
+			}
+			result := make(chan *feeResult, 1)
+			select {
+			case results <- result:
+			case <-done:
+				continue
+			}
+			go func(order *types.Order) {
+				fees, err := quoter.Quote(order)
+				result <- &feeResult{order, fees, err}
+			}(order)
 		}
-		takerFee, ok := new(big.Int).SetString(fees.TakerFee, 10)
-		if !ok {
-			log.Printf("TakerFee not a valid integer: '%v'", fees.TakerFee)
-			return subcommands.ExitFailure
+		close(results)
+	}()
+	fail := func() subcommands.ExitStatus {
+		close(done)
+		for _ = range results {
 		}
-		if makerShare >= 0 && makerShare < 1 {
-			totalFee := new(big.Int).Add(makerFee, takerFee)
-			makerPercent := big.NewInt(int64(makerShare * 100))
-			makerFee = new(big.Int).Div(new(big.Int).Mul(totalFee, makerPercent), big.NewInt(100))
-			takerFee = new(big.Int).Sub(totalFee, makerFee)
+		return subcommands.ExitFailure
+	}
+	for result := range results {
+		item := <-result
+		if item.err != nil {
+			log.Printf("Error getting fees: %v", item.err.Error())
+			return fail()
 		}
-		copy(order.MakerFee[:], abi.U256(makerFee))
-		copy(order.TakerFee[:], abi.U256(takerFee))
-		_, err = hex.Decode(order.FeeRecipient[:], []byte(fees.FeeRecipient)[2:])
-		if err != nil {
-			log.Printf("FeeRecipient not a valid hex string: %v", err.Error())
-			return subcommands.ExitFailure
+		if err := applyFees(item.order, item.fees, makerShare); err != nil {
+			log.Printf("Error setting fees: %v", err.Error())
+			return fail()
 		}
-		_, err = hex.Decode(order.Taker[:], []byte(fees.TakerToSpecify)[2:])
-		if err != nil {
-			log.Printf("FeeRecipient not a valid hex string: %v", err.Error())
-			return subcommands.ExitFailure
+		if err := utils.WriteRecord(item.order, outputFile); err != nil {
+			log.Printf("Error writing order: %v", err.Error())
+			return fail()
 		}
-
-		utils.WriteRecord(order, outputFile)
 	}
+	hits, misses := quoter.Stats()
+	log.Printf("Fee quotes: %v cache hits, %v misses", hits, misses)
 	return subcommands.ExitSuccess
 }
@@ -4,13 +4,86 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/zeroEx"
 	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
+// testRelayer quotes fees paid to its fee recipient and accepts uploaded
+// orders, recording the fee requests and orders it receives
+type testRelayer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []map[string]string
+	uploaded []*types.Order
+}
+
+func newTestRelayer(t *testing.T, feeRecipient string) *testRelayer {
+	relayer := &testRelayer{}
+	relayer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/v0/fees":
+			request := make(map[string]string)
+			if err := json.Unmarshal(body, &request); err != nil {
+				t.Errorf("Error parsing fee request '%v': %v", string(body), err.Error())
+			}
+			relayer.mutex.Lock()
+			relayer.requests = append(relayer.requests, request)
+			relayer.mutex.Unlock()
+			fmt.Fprintf(w, `{"feeRecipient":"%v","makerFee":"100","takerFee":"200","takerToSpecify":"0x0000000000000000000000000000000000000000"}`, feeRecipient)
+		case "/v0/order":
+			order := &types.Order{}
+			if err := json.Unmarshal(body, order); err != nil {
+				t.Errorf("Error parsing uploaded order: %v", err.Error())
+			}
+			relayer.mutex.Lock()
+			relayer.uploaded = append(relayer.uploaded, order)
+			relayer.mutex.Unlock()
+			w.WriteHeader(202)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	return relayer
+}
+
+// Requests returns the fee requests received so far
+func (relayer *testRelayer) Requests() []map[string]string {
+	relayer.mutex.Lock()
+	defer relayer.mutex.Unlock()
+	return append([]map[string]string{}, relayer.requests...)
+}
+
+// Uploaded returns the orders uploaded so far
+func (relayer *testRelayer) Uploaded() []*types.Order {
+	relayer.mutex.Lock()
+	defer relayer.mutex.Unlock()
+	return append([]*types.Order{}, relayer.uploaded...)
+}
+
+// testOrders returns count newline separated orders, each changed by setup
+func testOrders(count int, setup func(i int, order *types.Order)) *bytes.Buffer {
+	inputBuffer := &bytes.Buffer{}
+	for i := 0; i < count; i++ {
+		order := &types.Order{}
+		order.Initialize()
+		setup(i, order)
+		orderBytes, _ := json.Marshal(order)
+		inputBuffer.Write(append(orderBytes, '\n'))
+	}
+	return inputBuffer
+}
+
 func TestGetFees(t *testing.T) {
 	order := &types.Order{}
 	order.Initialize()
@@ -44,3 +117,214 @@ func TestGetFees(t *testing.T) {
 		t.Errorf("Expected TakerFee to be non-zero, got %v", processedOrder.TakerFee)
 	}
 }
+
+func TestGetFeesCache(t *testing.T) {
+	relayer := newTestRelayer(t, "0x0000000000000000000000000000000000000001")
+	defer relayer.Close()
+	// Six orders from two makers. The signatures tell them apart, but aren't
+	// part of the fee request.
+	inputBuffer := testOrders(6, func(i int, order *types.Order) {
+		order.Maker[19] = byte(i % 2)
+		order.Signature.R[0] = byte(i)
+	})
+	quoter := zeroEx.NewFeeQuoter(http.DefaultClient, relayer.URL, 0)
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.GetFeesQuoterMain(quoter, inputBuffer, outputBuffer, -1, 4); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		order := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatal(err.Error())
+		}
+		if order.Signature.R[0] != byte(counter) {
+			t.Errorf("Expected order %v, got %v", counter, order.Signature.R[0])
+		}
+		if order.MakerFee[31] != 100 || order.TakerFee[31] != 200 {
+			t.Errorf("Unexpected fees on order %v", counter)
+		}
+		counter++
+	}
+	if counter != 6 {
+		t.Errorf("Expected 6 orders, got %v", counter)
+	}
+	if requests := relayer.Requests(); len(requests) != 2 {
+		t.Errorf("Expected 2 fee requests, got %v", len(requests))
+	}
+	if hits, misses := quoter.Stats(); hits != 4 || misses != 2 {
+		t.Errorf("Expected 4 hits and 2 misses, got %v and %v", hits, misses)
+	}
+}
+
+func TestGetFeesCacheSalts(t *testing.T) {
+	relayer := newTestRelayer(t, "0x0000000000000000000000000000000000000001")
+	defer relayer.Close()
+	// Salted orders that are otherwise identical
+	inputBuffer := testOrders(4, func(i int, order *types.Order) {
+		order.Salt[31] = byte(i + 1)
+	})
+	quoter := zeroEx.NewFeeQuoter(http.DefaultClient, relayer.URL, 0)
+	if status := zeroEx.GetFeesQuoterMain(quoter, inputBuffer, &bytes.Buffer{}, -1, 1); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	requests := relayer.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 fee request, got %v", len(requests))
+	}
+	if requests[0]["salt"] != "1" {
+		t.Errorf("Expected the request to include the salt, got '%v'", requests[0]["salt"])
+	}
+	if hits, misses := quoter.Stats(); hits != 3 || misses != 1 {
+		t.Errorf("Expected 3 hits and 1 miss, got %v and %v", hits, misses)
+	}
+}
+
+func TestGetFeesRequestBody(t *testing.T) {
+	relayer := newTestRelayer(t, "0x0000000000000000000000000000000000000001")
+	defer relayer.Close()
+	inputBuffer := testOrders(1, func(i int, order *types.Order) {
+		order.ExchangeAddress[19] = 1
+		order.Maker[19] = 2
+		order.Taker[19] = 3
+		order.MakerToken[19] = 4
+		order.TakerToken[19] = 5
+		order.MakerTokenAmount[31] = 6
+		order.TakerTokenAmount[31] = 7
+		order.ExpirationTimestampInSec[31] = 8
+		order.Salt[31] = 9
+	})
+	if status := zeroEx.GetFeesMain(relayer.URL, inputBuffer, &bytes.Buffer{}, -1); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	requests := relayer.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 fee request, got %v", len(requests))
+	}
+	request := requests[0]
+	expected := map[string]string{
+		"exchangeContractAddress":    "0x0000000000000000000000000000000000000001",
+		"maker":                      "0x0000000000000000000000000000000000000002",
+		"taker":                      "0x0000000000000000000000000000000000000003",
+		"makerTokenAddress":          "0x0000000000000000000000000000000000000004",
+		"takerTokenAddress":          "0x0000000000000000000000000000000000000005",
+		"makerTokenAmount":           "6",
+		"takerTokenAmount":           "7",
+		"expirationUnixTimestampSec": "8",
+		"salt":                       "9",
+	}
+	for field, value := range expected {
+		if request[field] != value {
+			t.Errorf("Expected %v to be %v, got '%v'", field, value, request[field])
+		}
+	}
+	if _, ok := request["feeRecipient"]; ok {
+		t.Errorf("Expected no feeRecipient for an order without one")
+	}
+}
+
+func TestGetFeesInvalidResponse(t *testing.T) {
+	for _, response := range []string{
+		`{"feeRecipient":"0x","makerFee":"100","takerFee":"200","takerToSpecify":"0x0000000000000000000000000000000000000000"}`,
+		`{"feeRecipient":"0x0000000000000000000000000000000000000001","makerFee":"100","takerFee":"200"}`,
+		`{"feeRecipient":"0x0000000000000000000000000000000000000001","makerFee":"-100","takerFee":"200","takerToSpecify":"0x0000000000000000000000000000000000000000"}`,
+		`{"feeRecipient":"0x00000000000000000000000000000000000000zz","makerFee":"100","takerFee":"200","takerToSpecify":"0x0000000000000000000000000000000000000000"}`,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(response))
+		}))
+		order := &types.Order{}
+		order.Initialize()
+		orderBytes, _ := json.Marshal(order)
+		if status := zeroEx.GetFeesMain(server.URL, bytes.NewReader(orderBytes), &bytes.Buffer{}, -1); status != subcommands.ExitFailure {
+			t.Errorf("Expected failure for %v, got %v", response, status)
+		}
+		server.Close()
+	}
+}
+
+func TestGetFeesLocal(t *testing.T) {
+	configFile, err := ioutil.TempFile("", "fees")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(configFile.Name())
+	configFile.WriteString(`{
+		"baseFee": "1000",
+		"defaultFeeRecipient": "0x0000000000000000000000000000000000000001",
+		"affiliates": {
+			"0x0000000000000000000000000000000000000001": {"feePercent": 100},
+			"0x0000000000000000000000000000000000000002": {"feePercent": 20}
+		},
+		"accounts": {"0x0000000000000000000000000000000000000003": {"discountPercent": 50, "expiration": 4000000000}}
+	}`)
+	configFile.Close()
+	source, err := zeroEx.LoadLocalFeeSource(configFile.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Orders with no fee recipient, a discounted maker, and the second
+	// affiliate
+	inputBuffer := testOrders(3, func(i int, order *types.Order) {
+		if i == 1 {
+			order.Maker[19] = 3
+		}
+		if i == 2 {
+			order.FeeRecipient[19] = 2
+		}
+	})
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.GetFeesQuoterMain(zeroEx.NewFeeSourceQuoter(source, 0), inputBuffer, outputBuffer, -1, 1); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	expected := []struct {
+		feeRecipient byte
+		makerFee     int64
+	}{{1, 1000}, {1, 500}, {2, 200}}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		order := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatal(err.Error())
+		}
+		if order.FeeRecipient[19] != expected[counter].feeRecipient {
+			t.Errorf("Order %v: expected fee recipient %v, got %#x", counter, expected[counter].feeRecipient, order.FeeRecipient[:])
+		}
+		if makerFee := new(big.Int).SetBytes(order.MakerFee[:]).Int64(); makerFee != expected[counter].makerFee {
+			t.Errorf("Order %v: expected maker fee %v, got %v", counter, expected[counter].makerFee, makerFee)
+		}
+		if new(big.Int).SetBytes(order.TakerFee[:]).Sign() != 0 {
+			t.Errorf("Order %v: expected no taker fee", counter)
+		}
+		counter++
+	}
+	if counter != 3 {
+		t.Errorf("Expected 3 orders, got %v", counter)
+	}
+	// More orders than workers, so quotes are still in flight when the first
+	// one fails
+	inputBuffer = testOrders(20, func(i int, order *types.Order) {
+		order.FeeRecipient[19] = 4
+	})
+	if status := zeroEx.GetFeesQuoterMain(zeroEx.NewFeeSourceQuoter(source, 0), inputBuffer, &bytes.Buffer{}, -1, 4); status != subcommands.ExitFailure {
+		t.Errorf("Expected unknown fee recipient to fail, got %v", status)
+	}
+}
+
+func TestGetFeesTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	order := &types.Order{}
+	order.Initialize()
+	orderBytes, _ := json.Marshal(order)
+	quoter := zeroEx.NewFeeQuoter(&http.Client{Timeout: 50 * time.Millisecond}, server.URL, 0)
+	if status := zeroEx.GetFeesQuoterMain(quoter, bytes.NewReader(orderBytes), &bytes.Buffer{}, -1, 1); status != subcommands.ExitFailure {
+		t.Errorf("Expected a fee request timeout to fail, got %v", status)
+	}
+}
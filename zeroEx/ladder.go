@@ -0,0 +1,217 @@
+package zeroEx
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// The rules for dividing a ladder's total size between its levels
+const (
+	SizeEqual     = "equal"
+	SizeLinear    = "linear"
+	SizeGeometric = "geometric"
+)
+
+type ladder struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	tokenFiles     string
+	rpcURL         string
+	baseToken      string
+	quoteToken     string
+	side           string
+	startPrice     string
+	endPrice       string
+	levels         int
+	size           string
+	sizeRule       string
+	ratio          string
+	priceTolerance string
+}
+
+func (p *ladder) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *ladder) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*ladder) Name() string     { return "ladder" }
+func (*ladder) Synopsis() string { return "Generate a ladder of orders between two prices" }
+func (*ladder) Usage() string {
+	return `msv 0x ladder --base TOKEN --quote TOKEN --side buy|sell --start-price PRICE --end-price PRICE --levels N --size SIZE [--size-rule equal|linear|geometric] [--ratio R] [--tokens FILE[,FILE...]] [--rpc ETHEREUM_RPC_URL] [--output FILE]:
+  Write a ladder of orders to buy or sell a total of SIZE base tokens, at N
+  prices evenly spaced from the start price to the end price. Prices are in
+  quote tokens per base token, and tokens may be given as symbols or
+  addresses as with "msv 0x csv".
+
+  The size rule sets how the total size is divided between levels. "equal"
+  gives each level the same size, "linear" gives the Nth level N times the
+  size of the first, and "geometric" multiplies each level's size by --ratio.
+
+  Each order is written as a new order record, ready to be passed to
+  getFees, setSalt, expiration, sign and upload.
+`
+}
+
+func (p *ladder) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.tokenFiles, "tokens", "", "Comma separated token registry files")
+	f.StringVar(&p.rpcURL, "rpc", "", "Ethereum RPC URL for looking up token symbols and decimals")
+	f.StringVar(&p.baseToken, "base", "", "The base token symbol or address")
+	f.StringVar(&p.quoteToken, "quote", "", "The quote token symbol or address")
+	f.StringVar(&p.side, "side", "", "Whether the orders buy or sell the base token")
+	f.StringVar(&p.startPrice, "start-price", "", "The price of the first level, in quote tokens per base token")
+	f.StringVar(&p.endPrice, "end-price", "", "The price of the last level, in quote tokens per base token")
+	f.IntVar(&p.levels, "levels", 0, "The number of orders to create")
+	f.StringVar(&p.size, "size", "", "The total size of the orders, in base tokens")
+	f.StringVar(&p.sizeRule, "size-rule", SizeEqual, "How to divide the size between levels: equal, linear, or geometric")
+	f.StringVar(&p.ratio, "ratio", "2", "With --size-rule=geometric, the ratio between each level's size and the previous level's")
+	f.StringVar(&p.priceTolerance, "price-tolerance", "0.0001", "Warn when rounding moves a level's price by more than this fraction")
+}
+
+func (p *ladder) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 || p.baseToken == "" || p.quoteToken == "" || p.startPrice == "" || p.endPrice == "" || p.size == "" {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	tokens, err := loadTokenRegistry(p.rpcURL, p.tokenFiles)
+	if err != nil {
+		log.Printf("Error loading token registry: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	base, err := tokens.Resolve(p.baseToken)
+	if err != nil {
+		log.Printf("Error resolving base token: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	quote, err := tokens.Resolve(p.quoteToken)
+	if err != nil {
+		log.Printf("Error resolving quote token: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	startPrice, err := parseDecimal(p.startPrice)
+	if err != nil {
+		log.Printf("Error parsing start price: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	endPrice, err := parseDecimal(p.endPrice)
+	if err != nil {
+		log.Printf("Error parsing end price: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	size, err := base.BaseUnits(p.size)
+	if err != nil {
+		log.Printf("Error parsing size: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	ratio, err := parseDecimal(p.ratio)
+	if err != nil {
+		log.Printf("Error parsing ratio: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	priceTolerance, ok := new(big.Rat).SetString(p.priceTolerance)
+	if !ok {
+		log.Printf("Invalid price tolerance: %v", p.priceTolerance)
+		return subcommands.ExitUsageError
+	}
+	return LadderMain(p.outputFile, strings.ToLower(p.side), base, quote, startPrice, endPrice, p.levels, size, p.sizeRule, ratio, priceTolerance)
+}
+
+// ladderSizes divides size base units between levels according to the size
+// rule. Any remainder from rounding goes to the last level.
+func ladderSizes(size *big.Int, levels int, sizeRule string, ratio *big.Rat) ([]*big.Int, error) {
+	weights := make([]*big.Rat, levels)
+	totalWeight := new(big.Rat)
+	for i := range weights {
+		switch sizeRule {
+		case SizeEqual:
+			weights[i] = big.NewRat(1, 1)
+		case SizeLinear:
+			weights[i] = big.NewRat(int64(i+1), 1)
+		case SizeGeometric:
+			if ratio.Sign() <= 0 {
+				return nil, errors.New("ratio must be greater than zero")
+			}
+			weights[i] = big.NewRat(1, 1)
+			if i > 0 {
+				weights[i] = new(big.Rat).Mul(weights[i-1], ratio)
+			}
+		default:
+			return nil, fmt.Errorf("unknown size rule: %v", sizeRule)
+		}
+		totalWeight.Add(totalWeight, weights[i])
+	}
+	sizes := make([]*big.Int, levels)
+	remaining := new(big.Int).Set(size)
+	for i, weight := range weights {
+		if i == levels-1 {
+			sizes[i] = remaining
+			break
+		}
+		levelSize := new(big.Rat).SetInt(size)
+		levelSize.Mul(levelSize, weight)
+		levelSize.Quo(levelSize, totalWeight)
+		sizes[i] = new(big.Int).Quo(levelSize.Num(), levelSize.Denom())
+		remaining.Sub(remaining, sizes[i])
+	}
+	return sizes, nil
+}
+
+// ladderPrice returns the price of the level'th of levels evenly spaced
+// prices from startPrice to endPrice
+func ladderPrice(startPrice, endPrice *big.Rat, level, levels int) *big.Rat {
+	if levels == 1 {
+		return new(big.Rat).Set(startPrice)
+	}
+	step := new(big.Rat).Sub(endPrice, startPrice)
+	step.Mul(step, big.NewRat(int64(level), int64(levels-1)))
+	return step.Add(step, startPrice)
+}
+
+func LadderMain(outputFile io.Writer, side string, base, quote *Token, startPrice, endPrice *big.Rat, levels int, size *big.Int, sizeRule string, ratio, priceTolerance *big.Rat) subcommands.ExitStatus {
+	if levels < 1 {
+		log.Printf("--levels must be at least 1")
+		return subcommands.ExitFailure
+	}
+	sizes, err := ladderSizes(size, levels, sizeRule, ratio)
+	if err != nil {
+		log.Printf("Error dividing size: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	for i, levelSize := range sizes {
+		price := ladderPrice(startPrice, endPrice, i, levels)
+		amounts, err := orderFromPriceAmount(side, base, quote, price, levelSize)
+		if err != nil {
+			log.Printf("Error creating level %v at price %v: %v", i+1, price.FloatString(8), err.Error())
+			return subcommands.ExitFailure
+		}
+		if amounts.deviation.Cmp(priceTolerance) > 0 {
+			percent := new(big.Rat).Mul(amounts.deviation, big.NewRat(100, 1))
+			log.Printf("Warning: rounding moves the price of level %v by %v%%", i+1, percent.FloatString(4))
+		}
+		order := &types.Order{}
+		order.Initialize()
+		copy(order.MakerToken[:], amounts.makerToken.address[:])
+		copy(order.TakerToken[:], amounts.takerToken.address[:])
+		copy(order.MakerTokenAmount[:], abi.U256(amounts.makerAmount))
+		copy(order.TakerTokenAmount[:], abi.U256(amounts.takerAmount))
+		utils.WriteRecord(order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,54 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+)
+
+func TestLadderLinear(t *testing.T) {
+	tokens := zeroEx.NewTokenRegistry(nil)
+	var zrxDecimals, usdcDecimals uint8 = 18, 6
+	if err := tokens.Add(&zeroEx.Token{Symbol: "ZRX", Address: "0xa1df88ea6a08722055250ed65601872e59cddfaa", Decimals: &zrxDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := tokens.Add(&zeroEx.Token{Symbol: "USDC", Address: "0xc778417e063141139fce010982780140aa0cd5ab", Decimals: &usdcDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	base, _ := tokens.Symbol("ZRX")
+	quote, _ := tokens.Symbol("USDC")
+	size, _ := base.BaseUnits("6")
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.LadderMain(outputBuffer, zeroEx.SideSell, base, quote, big.NewRat(1, 1), big.NewRat(2, 1), 3, size, zeroEx.SizeLinear, big.NewRat(2, 1), big.NewRat(1, 10000)); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	// Sizes of 1, 2 and 3 ZRX at prices of 1, 1.5 and 2 USDC
+	expectedMaker := []string{"1000000000000000000", "2000000000000000000", "3000000000000000000"}
+	expectedTaker := []string{"1000000", "3000000", "6000000"}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		order := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		if counter >= len(expectedMaker) {
+			t.Fatalf("Too many orders")
+		}
+		if makerTokenAmount := new(big.Int).SetBytes(order.MakerTokenAmount[:]).String(); makerTokenAmount != expectedMaker[counter] {
+			t.Errorf("Level %v: unexpected makerTokenAmount %v", counter, makerTokenAmount)
+		}
+		if takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:]).String(); takerTokenAmount != expectedTaker[counter] {
+			t.Errorf("Level %v: unexpected takerTokenAmount %v", counter, takerTokenAmount)
+		}
+		counter++
+	}
+	if counter != 3 {
+		t.Errorf("Expected 3 orders, got %v", counter)
+	}
+}
@@ -0,0 +1,189 @@
+package zeroEx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	accountsModule "github.com/notegio/openrelay/accounts"
+	affiliatesModule "github.com/notegio/openrelay/affiliates"
+	"github.com/notegio/openrelay/config"
+	"github.com/notegio/openrelay/ingest"
+	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"math/big"
+)
+
+// localFeeConfig is the file format for getFees --local. Affiliates are keyed
+// by fee recipient address and accounts by maker address.
+type localFeeConfig struct {
+	BaseFee             string `json:"baseFee"`
+	DefaultFeeRecipient string `json:"defaultFeeRecipient"`
+	Affiliates          map[string]struct {
+		FeePercent int64 `json:"feePercent"`
+	} `json:"affiliates"`
+	Accounts map[string]localAccount `json:"accounts"`
+}
+
+// staticBaseFee is a config.BaseFee that keeps its value in memory
+type staticBaseFee struct {
+	value *big.Int
+}
+
+func (baseFee *staticBaseFee) Get() (*big.Int, error) {
+	return baseFee.value, nil
+}
+
+func (baseFee *staticBaseFee) Set(value *big.Int) error {
+	baseFee.value = value
+	return nil
+}
+
+// localAffiliateService is an affiliates.AffiliateService with fee
+// percentages from a config file. Like openrelay's service, it looks up the
+// base fee whenever an affiliate is requested.
+type localAffiliateService struct {
+	baseFee     config.BaseFee
+	feePercents map[types.Address]int64
+}
+
+func (service *localAffiliateService) Get(address *types.Address) (affiliatesModule.Affiliate, error) {
+	feePercent, ok := service.feePercents[*address]
+	if !ok {
+		return nil, fmt.Errorf("%v is not an affiliate", address)
+	}
+	baseFee, err := service.baseFee.Get()
+	if err != nil {
+		return nil, err
+	}
+	return affiliatesModule.NewAffiliate(baseFee, feePercent), nil
+}
+
+func (service *localAffiliateService) Set(address *types.Address, affiliate affiliatesModule.Affiliate) error {
+	return errors.New("local affiliates can only be set in the config file")
+}
+
+// localAccount is a maker's account settings from a config file
+type localAccount struct {
+	Blacklisted     bool  `json:"blacklisted"`
+	DiscountPercent int64 `json:"discountPercent"`
+	Expiration      int64 `json:"expiration"`
+}
+
+// localAccountService is an accounts.AccountService with accounts from a
+// config file. Makers without an account get no discount, as with
+// openrelay's account service.
+type localAccountService struct {
+	baseFee  config.BaseFee
+	accounts map[types.Address]localAccount
+}
+
+func (service *localAccountService) Get(address *types.Address) accountsModule.Account {
+	account, ok := service.accounts[*address]
+	baseFee, err := service.baseFee.Get()
+	if !ok || err != nil {
+		return accountsModule.NewAccount(false, new(big.Int), 0, 0)
+	}
+	return accountsModule.NewAccount(account.Blacklisted, baseFee, account.DiscountPercent, account.Expiration)
+}
+
+func (service *localAccountService) Set(address *types.Address, account accountsModule.Account) error {
+	return errors.New("local accounts can only be set in the config file")
+}
+
+// LocalFeeSource calculates fees the way openrelay's fee handler does, from a
+// base fee, affiliate fee percentages and maker account discounts, without
+// making any requests. BaseFee is kept for changing the base fee, which
+// Affiliates and Accounts use.
+type LocalFeeSource struct {
+	BaseFee             config.BaseFee
+	Affiliates          affiliatesModule.AffiliateService
+	Accounts            accountsModule.AccountService
+	DefaultFeeRecipient *types.Address
+}
+
+func parseConfigAddress(value string) (*types.Address, error) {
+	if !common.IsHexAddress(value) {
+		return nil, fmt.Errorf("invalid address: '%v'", value)
+	}
+	address := &types.Address{}
+	copy(address[:], common.HexToAddress(value).Bytes())
+	return address, nil
+}
+
+// LoadLocalFeeSource reads a LocalFeeSource from a JSON config file such as
+//
+//	{
+//	  "baseFee": "100000000000000000",
+//	  "defaultFeeRecipient": "0xc22d5b2951db72b44cfb8089bb8cd374a3c354ea",
+//	  "affiliates": {"0xc22d5b2951db72b44cfb8089bb8cd374a3c354ea": {"feePercent": 100}},
+//	  "accounts": {"0x324454186bb728a3ea55750e0618ff1b18ce6cf8": {"discountPercent": 50, "expiration": 1600000000}}
+//	}
+func LoadLocalFeeSource(fileName string) (*LocalFeeSource, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	feeConfig := &localFeeConfig{}
+	if err := json.Unmarshal(data, feeConfig); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", fileName, err.Error())
+	}
+	baseFeeValue, ok := new(big.Int).SetString(feeConfig.BaseFee, 10)
+	if !ok || baseFeeValue.Sign() < 0 {
+		return nil, fmt.Errorf("invalid base fee: '%v'", feeConfig.BaseFee)
+	}
+	baseFee := &staticBaseFee{baseFeeValue}
+	affiliates := &localAffiliateService{baseFee, make(map[types.Address]int64)}
+	accounts := &localAccountService{baseFee, make(map[types.Address]localAccount)}
+	source := &LocalFeeSource{
+		BaseFee:             baseFee,
+		Affiliates:          affiliates,
+		Accounts:            accounts,
+		DefaultFeeRecipient: &types.Address{},
+	}
+	if feeConfig.DefaultFeeRecipient != "" {
+		if source.DefaultFeeRecipient, err = parseConfigAddress(feeConfig.DefaultFeeRecipient); err != nil {
+			return nil, fmt.Errorf("defaultFeeRecipient: %v", err.Error())
+		}
+	}
+	for value, affiliate := range feeConfig.Affiliates {
+		address, err := parseConfigAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("affiliates: %v", err.Error())
+		}
+		affiliates.feePercents[*address] = affiliate.FeePercent
+	}
+	for value, account := range feeConfig.Accounts {
+		address, err := parseConfigAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("accounts: %v", err.Error())
+		}
+		accounts.accounts[*address] = account
+	}
+	return source, nil
+}
+
+// Fees returns the fees openrelay would quote for order. As with openrelay,
+// the whole fee is charged to the maker, and is the fee recipient's fee less
+// the maker's discount.
+func (source *LocalFeeSource) Fees(order *types.Order) (*ingest.FeeResponse, error) {
+	feeRecipient := order.FeeRecipient
+	if *feeRecipient == (types.Address{}) {
+		feeRecipient = source.DefaultFeeRecipient
+	}
+	affiliate, err := source.Affiliates.Get(feeRecipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee recipient: %v", err.Error())
+	}
+	if affiliate == nil {
+		return nil, errors.New("invalid fee recipient")
+	}
+	account := source.Accounts.Get(order.Maker)
+	makerFee := new(big.Int).Sub(affiliate.Fee(), account.Discount())
+	return &ingest.FeeResponse{
+		MakerFee:       makerFee.Text(10),
+		TakerFee:       "0",
+		FeeRecipient:   fmt.Sprintf("%#x", feeRecipient[:]),
+		TakerToSpecify: fmt.Sprintf("%#x", make([]byte, 20)),
+	}, nil
+}
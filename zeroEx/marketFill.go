@@ -0,0 +1,259 @@
+package zeroEx
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+)
+
+type marketFill struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	takerAmount    string
+	keyOpts        utils.KeyOptions
+	txOpts         transactionOptions
+}
+
+func (p *marketFill) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *marketFill) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*marketFill) Name() string     { return "marketFill" }
+func (*marketFill) Synopsis() string { return "Fill the best priced orders up to a taker token amount" }
+func (*marketFill) Usage() string {
+	return `msv 0x marketFill --taker-amount AMOUNT [--dry-run] [--password-file FILE | --password-env VAR] [--gas-price WEI | --gas-price-strategy STRATEGY] [--gas-limit GAS] [--max-gas-price WEI] [--chain-id ID] [--input FILE] [--output FILE] ETHEREUM_RPC_URL KEY_FILE:
+  Read candidate orders for a single token pair, sort them by price, and fill
+  the smallest set of orders that covers AMOUNT of the taker token, using the
+  specified key as the taker. If all chosen orders are on the same exchange
+  contract they are filled in a single fillOrdersUpTo transaction, otherwise
+  each exchange contract gets a batchFillOrders transaction. Expired orders,
+  orders with no maker token amount and orders reserved for another taker
+  are skipped.
+
+  With --dry-run, the chosen orders are written to the output along with the
+  amounts that would be filled and the calldata, estimated gas and cost of
+  the fill transactions, and the expected average price is logged, but no
+  transactions are sent.
+
+  KEY_FILE may be a plaintext private key or an encrypted geth keystore file.
+`
+}
+
+func (p *marketFill) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.takerAmount, "taker-amount", "", "The total taker token amount to fill, in base units")
+	p.keyOpts.SetFlags(f)
+	p.txOpts.SetFlags(f)
+}
+
+func (p *marketFill) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 || p.takerAmount == "" {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	takerAmount, ok := new(big.Int).SetString(p.takerAmount, 10)
+	if !ok {
+		log.Printf("Error processing taker amount: %v", p.takerAmount)
+		return subcommands.ExitFailure
+	}
+	client, err := rpc.Dial(f.Arg(0))
+	if err != nil {
+		log.Printf("Error establishing Ethereum connection: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	conn := ethclient.NewClient(client)
+	privKey, err := p.keyOpts.LoadKey(f.Arg(1))
+	if err != nil {
+		log.Printf("Error loading key: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	sender, err := p.txOpts.Sender(client, conn)
+	if err != nil {
+		log.Printf("Error configuring transactions: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return MarketFillMain(p.inputFile, p.outputFile, conn, sender, privKey, takerAmount)
+}
+
+// MarketFillOrder is an order chosen to be filled, along with the amount of
+// it to fill.
+type MarketFillOrder struct {
+	Order       *types.Order
+	TakerAmount *big.Int
+}
+
+// MarketFillable reports whether taker can fill order. Orders with no maker
+// token amount would take the taker's tokens and give nothing back, and
+// orders reserved for another taker would revert the whole fill transaction.
+func MarketFillable(order *types.Order, taker *types.Address) bool {
+	if new(big.Int).SetBytes(order.MakerTokenAmount[:]).Sign() == 0 {
+		return false
+	}
+	return *order.Taker == (types.Address{}) || *order.Taker == *taker
+}
+
+// ChooseMarketOrders picks the lowest priced orders until takerAmount is
+// covered, filling each chosen order up to its remaining amount. It returns
+// the chosen orders and the taker token amount they cover. remaining is
+// keyed by order hash.
+func ChooseMarketOrders(orders []*types.Order, remaining map[[32]byte]*big.Int, takerAmount *big.Int) ([]*MarketFillOrder, *big.Int) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orderPrice(orders[i]).Cmp(orderPrice(orders[j])) < 0
+	})
+	needed := new(big.Int).Set(takerAmount)
+	chosen := []*MarketFillOrder{}
+	for _, order := range orders {
+		if needed.Sign() == 0 {
+			break
+		}
+		available := remaining[orderHash(order)]
+		if available == nil || available.Sign() == 0 {
+			continue
+		}
+		amount := new(big.Int).Set(available)
+		if amount.Cmp(needed) > 0 {
+			amount.Set(needed)
+		}
+		needed.Sub(needed, amount)
+		chosen = append(chosen, &MarketFillOrder{order, amount})
+	}
+	return chosen, new(big.Int).Sub(takerAmount, needed)
+}
+
+// sendMarketFill fills the chosen orders on a single exchange contract,
+// returning the transaction and its mined receipt.
+func sendMarketFill(sender *TransactionSender, key *ecdsa.PrivateKey, exchange *exchangecontract.Exchange, chosen []*MarketFillOrder, upTo bool) (*gethTypes.Transaction, *gethTypes.Receipt, error) {
+	orders := make([]*types.Order, len(chosen))
+	amounts := make([]*big.Int, len(chosen))
+	total := new(big.Int)
+	for i, item := range chosen {
+		orders[i] = item.Order
+		amounts[i] = item.TakerAmount
+		total.Add(total, item.TakerAmount)
+	}
+	addresses, values, v, r, s := batchOrderArgs(orders)
+	return sender.TransactAndWait(key, func(opts *bind.TransactOpts) (*gethTypes.Transaction, error) {
+		if upTo {
+			return rawExchangeTransact(exchange, opts, "fillOrdersUpTo", addresses, values, total, false, v, r, s)
+		}
+		return rawExchangeTransact(exchange, opts, "batchFillOrders", addresses, values, amounts, false, v, r, s)
+	})
+}
+
+func MarketFillMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, sender *TransactionSender, key *ecdsa.PrivateKey, takerAmount *big.Int) subcommands.ExitStatus {
+	orders := []*types.Order{}
+	remaining := make(map[[32]byte]*big.Int)
+	exchanges := make(map[types.Address]*exchangecontract.Exchange)
+	now := big.NewInt(time.Now().Unix())
+	taker := &types.Address{}
+	copy(taker[:], crypto.PubkeyToAddress(key.PublicKey).Bytes())
+	for order := range orderScanner(inputFile) {
+		if len(orders) > 0 && (!bytes.Equal(order.MakerToken[:], orders[0].MakerToken[:]) || !bytes.Equal(order.TakerToken[:], orders[0].TakerToken[:])) {
+			log.Printf("Order %#x is not for the same token pair as the other orders", order.Hash())
+			return subcommands.ExitFailure
+		}
+		if new(big.Int).SetBytes(order.ExpirationTimestampInSec[:]).Cmp(now) <= 0 {
+			continue
+		}
+		if !MarketFillable(order, taker) {
+			log.Printf("Skipping order %#x, which cannot be filled by %v", order.Hash(), taker)
+			continue
+		}
+		exchange, ok := exchanges[*order.ExchangeAddress]
+		if !ok {
+			var err error
+			exchange, err = exchangecontract.NewExchange(orCommon.ToGethAddress(order.ExchangeAddress), conn)
+			if err != nil {
+				log.Printf("Error initializing exchange contract %v: %v", order.ExchangeAddress, err.Error())
+				return subcommands.ExitFailure
+			}
+			exchanges[*order.ExchangeAddress] = exchange
+		}
+		available, err := remainingTakerAmount(exchange, order)
+		if err != nil {
+			log.Printf("Error getting remaining amount for order %#x: %v", order.Hash(), err.Error())
+			return subcommands.ExitFailure
+		}
+		remaining[orderHash(order)] = available
+		orders = append(orders, order)
+	}
+	chosen, covered := ChooseMarketOrders(orders, remaining, takerAmount)
+	if len(chosen) == 0 {
+		log.Printf("No fillable orders found")
+		return subcommands.ExitFailure
+	}
+	if covered.Cmp(takerAmount) < 0 {
+		log.Printf("Orders only cover %v of the requested %v taker tokens", covered, takerAmount)
+	}
+	expectedMakerAmount := new(big.Int)
+	for _, item := range chosen {
+		expectedMakerAmount.Add(expectedMakerAmount, partialMakerAmount(item.Order, item.TakerAmount))
+	}
+	if expectedMakerAmount.Sign() > 0 {
+		averagePrice := new(big.Rat).SetFrac(covered, expectedMakerAmount)
+		log.Printf("Filling %v taker tokens for %v maker tokens across %v orders, average price %v", covered, expectedMakerAmount, len(chosen), averagePrice.FloatString(18))
+	}
+	exchangeChosen := make(map[types.Address][]*MarketFillOrder)
+	exchangeAddresses := []types.Address{}
+	for _, item := range chosen {
+		if _, ok := exchangeChosen[*item.Order.ExchangeAddress]; !ok {
+			exchangeAddresses = append(exchangeAddresses, *item.Order.ExchangeAddress)
+		}
+		exchangeChosen[*item.Order.ExchangeAddress] = append(exchangeChosen[*item.Order.ExchangeAddress], item)
+	}
+	failed := 0
+	for _, exchangeAddress := range exchangeAddresses {
+		items := exchangeChosen[exchangeAddress]
+		transaction, receipt, err := sendMarketFill(sender, key, exchanges[exchangeAddress], items, len(exchangeAddresses) == 1)
+		for _, item := range items {
+			var result *fillResult
+			if err != nil {
+				result = &fillResult{filled: new(big.Int), err: err.Error()}
+			} else if sender.DryRun {
+				result = &fillResult{filled: item.TakerAmount, dryRun: dryRunAnnotations(transaction)}
+				result.dryRun["expectedMakerTokenAmount"] = partialMakerAmount(item.Order, item.TakerAmount).String()
+			} else {
+				result = receiptFillResult(receipt, item.Order)
+			}
+			if result.err != "" {
+				failed++
+				log.Printf("Error filling order %#x: %v", item.Order.Hash(), result.err)
+			}
+			if err := writeAnnotatedOrder(item.Order, result.annotations(), outputFile); err != nil {
+				log.Printf("Error writing result: %v", err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+	}
+	sender.LogDryRunCost()
+	if failed > 0 {
+		log.Printf("Failed to fill %v orders", failed)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
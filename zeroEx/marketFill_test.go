@@ -0,0 +1,97 @@
+package zeroEx_test
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+)
+
+func marketTestOrder(salt byte, makerAmount, takerAmount int64) *types.Order {
+	order := &types.Order{}
+	order.Initialize()
+	order.Salt[31] = salt
+	copy(order.MakerTokenAmount[:], abi.U256(big.NewInt(makerAmount)))
+	copy(order.TakerTokenAmount[:], abi.U256(big.NewInt(takerAmount)))
+	return order
+}
+
+func marketTestHash(order *types.Order) [32]byte {
+	hash := [32]byte{}
+	copy(hash[:], order.Hash())
+	return hash
+}
+
+func TestChooseMarketOrders(t *testing.T) {
+	// Prices of 3, 1, 2 and 1 taker tokens per maker token
+	orders := []*types.Order{
+		marketTestOrder(0, 100, 300),
+		marketTestOrder(1, 100, 100),
+		marketTestOrder(2, 100, 200),
+		marketTestOrder(3, 100, 100),
+	}
+	remaining := map[[32]byte]*big.Int{
+		marketTestHash(orders[0]): big.NewInt(300),
+		marketTestHash(orders[1]): big.NewInt(100),
+		marketTestHash(orders[2]): big.NewInt(200),
+		// Already filled
+		marketTestHash(orders[3]): big.NewInt(0),
+	}
+	items := []struct {
+		takerAmount int64
+		salts       []byte
+		amounts     []int64
+		covered     int64
+	}{
+		{50, []byte{1}, []int64{50}, 50},
+		{250, []byte{1, 2}, []int64{100, 150}, 250},
+		{450, []byte{1, 2, 0}, []int64{100, 200, 150}, 450},
+		{1000, []byte{1, 2, 0}, []int64{100, 200, 300}, 600},
+	}
+	for _, item := range items {
+		chosen, covered := zeroEx.ChooseMarketOrders(orders, remaining, big.NewInt(item.takerAmount))
+		if covered.Int64() != item.covered {
+			t.Errorf("%v: expected %v covered, got %v", item.takerAmount, item.covered, covered)
+		}
+		if len(chosen) != len(item.salts) {
+			t.Fatalf("%v: expected %v orders, got %v", item.takerAmount, len(item.salts), len(chosen))
+		}
+		for i, choice := range chosen {
+			if choice.Order.Salt[31] != item.salts[i] {
+				t.Errorf("%v: expected order %v at %v, got %v", item.takerAmount, item.salts[i], i, choice.Order.Salt[31])
+			}
+			if choice.TakerAmount.Int64() != item.amounts[i] {
+				t.Errorf("%v: expected to fill %v of order %v, got %v", item.takerAmount, item.amounts[i], item.salts[i], choice.TakerAmount)
+			}
+		}
+	}
+}
+
+func TestMarketFillable(t *testing.T) {
+	taker := &types.Address{}
+	taker[19] = 1
+	other := &types.Address{}
+	other[19] = 2
+	open := marketTestOrder(0, 100, 100)
+	reserved := marketTestOrder(1, 100, 100)
+	*reserved.Taker = *taker
+	reservedOther := marketTestOrder(2, 100, 100)
+	*reservedOther.Taker = *other
+	empty := marketTestOrder(3, 0, 100)
+	items := []struct {
+		name     string
+		order    *types.Order
+		fillable bool
+	}{
+		{"open", open, true},
+		{"reserved for taker", reserved, true},
+		{"reserved for another taker", reservedOther, false},
+		{"no maker amount", empty, false},
+	}
+	for _, item := range items {
+		if fillable := zeroEx.MarketFillable(item.order, taker); fillable != item.fillable {
+			t.Errorf("%v: expected %v, got %v", item.name, item.fillable, fillable)
+		}
+	}
+}
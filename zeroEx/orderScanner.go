@@ -8,18 +8,69 @@ import (
 	"log"
 )
 
+// orderAmounts holds the filled and cancelled amounts that the status command
+// adds to order records. The standard order serialization omits them.
+type orderAmounts struct {
+	TakerTokenAmountFilled    string `json:"takerTokenAmountFilled"`
+	TakerTokenAmountCancelled string `json:"takerTokenAmountCancelled"`
+}
+
+// parseOrder parses an order record, including the amounts status adds
+func parseOrder(line []byte) *types.Order {
+	order := &types.Order{}
+	err := json.Unmarshal(line, order)
+	if err != nil {
+		log.Fatalf("Error parsing record: %v", err.Error())
+	}
+	amounts := &orderAmounts{}
+	if err := json.Unmarshal(line, amounts); err != nil {
+		log.Fatalf("Error parsing record: %v", err.Error())
+	}
+	if amounts.TakerTokenAmountFilled != "" {
+		if order.TakerTokenAmountFilled, err = types.IntStringToUint256(amounts.TakerTokenAmountFilled); err != nil {
+			log.Fatalf("Error parsing takerTokenAmountFilled: %v", err.Error())
+		}
+	}
+	if amounts.TakerTokenAmountCancelled != "" {
+		if order.TakerTokenAmountCancelled, err = types.IntStringToUint256(amounts.TakerTokenAmountCancelled); err != nil {
+			log.Fatalf("Error parsing takerTokenAmountCancelled: %v", err.Error())
+		}
+	}
+	return order
+}
+
 func orderScanner(fd io.Reader) chan *types.Order {
 	channel := make(chan *types.Order)
+	go func() {
+		scanner := bufio.NewScanner(fd)
+		for scanner.Scan() {
+			channel <- parseOrder(scanner.Bytes())
+		}
+		close(channel)
+	}()
+	return channel
+}
+
+// orderRecord is an order along with every field of the record it was read
+// from, including annotations added by earlier commands
+type orderRecord struct {
+	order  *types.Order
+	fields map[string]interface{}
+}
+
+// recordScanner reads order records, keeping their annotations so commands
+// that pass orders through unchanged can write them back out
+func recordScanner(fd io.Reader) chan *orderRecord {
+	channel := make(chan *orderRecord)
 	go func() {
 		scanner := bufio.NewScanner(fd)
 		for scanner.Scan() {
 			line := scanner.Bytes()
-			order := &types.Order{}
-			err := json.Unmarshal(line, order)
-			if err != nil {
+			record := &orderRecord{order: parseOrder(line)}
+			if err := json.Unmarshal(line, &record.fields); err != nil {
 				log.Fatalf("Error parsing record: %v", err.Error())
 			}
-			channel <- order
+			channel <- record
 		}
 		close(channel)
 	}()
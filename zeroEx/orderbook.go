@@ -0,0 +1,87 @@
+package zeroEx
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type orderbook struct {
+	targetURL      string
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	baseToken      string
+	quoteToken     string
+	timeout        time.Duration
+}
+
+func (p *orderbook) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *orderbook) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*orderbook) Name() string     { return "orderbook" }
+func (*orderbook) Synopsis() string { return "Download a token pair's orderbook from a relayer" }
+func (*orderbook) Usage() string {
+	return `msv 0x orderbook --base ADDRESS --quote ADDRESS [--target RELAYER_URL] [--timeout DURATION] [--output FILE]:
+  Download the orderbook for a token pair from the target relayer's Standard
+  Relayer API, writing each order to the output with a "side" field of either
+  "bid" or "ask".
+`
+}
+
+func (p *orderbook) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.targetURL, "target", "https://api.openrelay.xyz", "Set the target 0x relayer")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.baseToken, "base", "", "The base token address")
+	f.StringVar(&p.quoteToken, "quote", "", "The quote token address")
+	f.DurationVar(&p.timeout, "timeout", 30*time.Second, "The timeout for the request")
+}
+
+func (p *orderbook) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 || p.baseToken == "" || p.quoteToken == "" {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	return OrderbookMain(&http.Client{Timeout: p.timeout}, p.targetURL, p.outputFile, p.baseToken, p.quoteToken)
+}
+
+type orderbookResponse struct {
+	Bids []*types.Order `json:"bids"`
+	Asks []*types.Order `json:"asks"`
+}
+
+func OrderbookMain(client *http.Client, targetURL string, outputFile io.Writer, baseToken, quoteToken string) subcommands.ExitStatus {
+	targetURL = strings.TrimSuffix(targetURL, "/")
+	query := url.Values{}
+	query.Set("baseTokenAddress", baseToken)
+	query.Set("quoteTokenAddress", quoteToken)
+	book := &orderbookResponse{}
+	if err := getJSON(client, fmt.Sprintf("%v/v0/orderbook?%v", targetURL, query.Encode()), book); err != nil {
+		log.Printf("Error getting orderbook from %v: %v", targetURL, err.Error())
+		return subcommands.ExitFailure
+	}
+	for _, order := range book.Bids {
+		writeAnnotatedOrder(order, map[string]interface{}{"side": "bid"}, outputFile)
+	}
+	for _, order := range book.Asks {
+		writeAnnotatedOrder(order, map[string]interface{}{"side": "ask"}, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,151 @@
+package zeroEx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type orders struct {
+	targetURL      string
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	perPage        int
+	timeout        time.Duration
+	filters        map[string]*string
+}
+
+// orderFilters are the query parameters the Standard Relayer API accepts on
+// /v0/orders
+var orderFilters = []string{
+	"exchangeContractAddress",
+	"tokenAddress",
+	"makerTokenAddress",
+	"takerTokenAddress",
+	"maker",
+	"taker",
+	"trader",
+	"feeRecipient",
+}
+
+func (p *orders) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *orders) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*orders) Name() string     { return "orders" }
+func (*orders) Synopsis() string { return "Download orders from a relayer" }
+func (*orders) Usage() string {
+	return `msv 0x orders [--target RELAYER_URL] [--per-page N] [--timeout DURATION] [--maker ADDRESS] [--taker ADDRESS] [--trader ADDRESS] [--tokenAddress ADDRESS] [--makerTokenAddress ADDRESS] [--takerTokenAddress ADDRESS] [--feeRecipient ADDRESS] [--exchangeContractAddress ADDRESS] [--output FILE]:
+  Page through the orders on the target relayer's Standard Relayer API,
+  writing each order to the output. Filters are passed through to the
+  relayer. Pages are requested until the relayer returns an empty page, and
+  downloading stops with an error if the relayer returns the same page
+  twice.
+`
+}
+
+func (p *orders) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.targetURL, "target", "https://api.openrelay.xyz", "Set the target 0x relayer")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.IntVar(&p.perPage, "per-page", 100, "The number of orders to request per page")
+	f.DurationVar(&p.timeout, "timeout", 30*time.Second, "The timeout for each request")
+	p.filters = make(map[string]*string)
+	for _, filter := range orderFilters {
+		p.filters[filter] = f.String(filter, "", fmt.Sprintf("Only download orders matching this %v", filter))
+	}
+}
+
+func (p *orders) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	filters := url.Values{}
+	for name, value := range p.filters {
+		if *value != "" {
+			filters.Set(name, *value)
+		}
+	}
+	return OrdersMain(&http.Client{Timeout: p.timeout}, p.targetURL, p.outputFile, filters, p.perPage)
+}
+
+// getJSON requests targetURL and parses the JSON response into result.
+func getJSON(client *http.Client, targetURL string, result interface{}) error {
+	resp, err := client.Get(targetURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected status code %v: %v", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("%v - '%v'", err.Error(), string(body))
+	}
+	return nil
+}
+
+// OrdersMain downloads every page of orders matching filters. Relayers may
+// return fewer than perPage orders on a page, so only an empty page ends the
+// download.
+func OrdersMain(client *http.Client, targetURL string, outputFile io.Writer, filters url.Values, perPage int) subcommands.ExitStatus {
+	targetURL = strings.TrimSuffix(targetURL, "/")
+	if perPage < 1 {
+		log.Printf("--per-page must be at least 1")
+		return subcommands.ExitFailure
+	}
+	counter := 0
+	var lastHash []byte
+	for page := 1; ; page++ {
+		query := url.Values{}
+		for name, values := range filters {
+			query[name] = values
+		}
+		query.Set("page", fmt.Sprintf("%v", page))
+		query.Set("per_page", fmt.Sprintf("%v", perPage))
+		orders := []*types.Order{}
+		if err := getJSON(client, fmt.Sprintf("%v/v0/orders?%v", targetURL, query.Encode()), &orders); err != nil {
+			log.Printf("Error getting orders from %v: %v", targetURL, err.Error())
+			return subcommands.ExitFailure
+		}
+		if len(orders) == 0 {
+			break
+		}
+		hash := orders[0].Hash()
+		if bytes.Equal(hash, lastHash) {
+			log.Printf("Error getting orders from %v: page %v repeats the previous page, the relayer may not support paging", targetURL, page)
+			return subcommands.ExitFailure
+		}
+		lastHash = hash
+		for _, order := range orders {
+			counter++
+			utils.WriteRecord(order, outputFile)
+		}
+	}
+	log.Printf("Downloaded %v orders from %v", counter, targetURL)
+	return subcommands.ExitSuccess
+}
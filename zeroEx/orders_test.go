@@ -0,0 +1,152 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOrdersPaging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/orders" {
+			t.Errorf("Unexpected path: %v", r.URL.Path)
+		}
+		if maker := r.URL.Query().Get("maker"); maker != "0x324454186bb728a3ea55750e0618ff1b18ce6cf8" {
+			t.Errorf("Unexpected maker filter: %v", maker)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		orders := []*types.Order{}
+		// Two full pages of two orders, then a partial page
+		if page <= 3 {
+			count := 2
+			if page == 3 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				order := &types.Order{}
+				order.Initialize()
+				order.Salt[31] = byte(page*10 + i)
+				orders = append(orders, order)
+			}
+		}
+		json.NewEncoder(w).Encode(orders)
+	}))
+	defer server.Close()
+	outputBuffer := &bytes.Buffer{}
+	outputFile := bufio.NewWriter(outputBuffer)
+	filters := url.Values{}
+	filters.Set("maker", "0x324454186bb728a3ea55750e0618ff1b18ce6cf8")
+	if status := zeroEx.OrdersMain(http.DefaultClient, server.URL, outputFile, filters, 2); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	outputFile.Flush()
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		processedOrder := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), processedOrder); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		counter++
+	}
+	if counter != 5 {
+		t.Errorf("Expected 5 orders, got %v", counter)
+	}
+}
+
+func TestOrdersPagingShortPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		orders := []*types.Order{}
+		// The relayer caps pages at one order, fewer than requested
+		if page <= 3 {
+			order := &types.Order{}
+			order.Initialize()
+			order.Salt[31] = byte(page)
+			orders = append(orders, order)
+		}
+		json.NewEncoder(w).Encode(orders)
+	}))
+	defer server.Close()
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.OrdersMain(http.DefaultClient, server.URL, outputBuffer, url.Values{}, 100); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	if lines := bytes.Count(outputBuffer.Bytes(), []byte("\n")); lines != 3 {
+		t.Errorf("Expected 3 orders, got %v", lines)
+	}
+}
+
+func TestOrdersPagingRepeated(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// The relayer ignores the page parameter
+		order := &types.Order{}
+		order.Initialize()
+		json.NewEncoder(w).Encode([]*types.Order{order})
+	}))
+	defer server.Close()
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.OrdersMain(http.DefaultClient, server.URL, outputBuffer, url.Values{}, 1); status != subcommands.ExitFailure {
+		t.Errorf("Expected repeated pages to fail, got %v", status)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %v", requests)
+	}
+	if lines := bytes.Count(outputBuffer.Bytes(), []byte("\n")); lines != 1 {
+		t.Errorf("Expected 1 order, got %v", lines)
+	}
+}
+
+func TestOrdersTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	if status := zeroEx.OrdersMain(client, server.URL, &bytes.Buffer{}, url.Values{}, 100); status != subcommands.ExitFailure {
+		t.Errorf("Expected a timeout to fail, got %v", status)
+	}
+}
+
+func TestOrderbook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/orderbook" {
+			t.Errorf("Unexpected path: %v", r.URL.Path)
+		}
+		order := &types.Order{}
+		order.Initialize()
+		orderBytes, _ := json.Marshal(order)
+		fmt.Fprintf(w, `{"bids": [%v], "asks": [%v, %v]}`, string(orderBytes), string(orderBytes), string(orderBytes))
+	}))
+	defer server.Close()
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.OrderbookMain(http.DefaultClient, server.URL, outputBuffer, "0xa1df88ea6a08722055250ed65601872e59cddfaa", "0xc778417e063141139fce010982780140aa0cd5ab"); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	sides := map[string]int{}
+	scanner := bufio.NewScanner(outputBuffer)
+	for scanner.Scan() {
+		record := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		sides[fmt.Sprintf("%v", record["side"])]++
+	}
+	if sides["bid"] != 1 || sides["ask"] != 2 {
+		t.Errorf("Unexpected sides: %v", sides)
+	}
+}
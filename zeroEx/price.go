@@ -0,0 +1,108 @@
+package zeroEx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+)
+
+// orderPrice returns the price of an order as the number of taker tokens
+// paid per maker token, in base units. Orders with no maker token amount have
+// a price of zero.
+func orderPrice(order *types.Order) *big.Rat {
+	makerTokenAmount := new(big.Int).SetBytes(order.MakerTokenAmount[:])
+	if makerTokenAmount.Sign() == 0 {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(new(big.Int).SetBytes(order.TakerTokenAmount[:]), makerTokenAmount)
+}
+
+// partialMakerAmount returns the maker token amount received for filling
+// takerAmount of the order, rounded down the same way the exchange
+// contract's getPartialAmount is.
+func partialMakerAmount(order *types.Order, takerAmount *big.Int) *big.Int {
+	takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:])
+	if takerTokenAmount.Sign() == 0 {
+		return new(big.Int)
+	}
+	result := new(big.Int).Mul(takerAmount, new(big.Int).SetBytes(order.MakerTokenAmount[:]))
+	return result.Div(result, takerTokenAmount)
+}
+
+// The sides of a price based order, from the maker's point of view
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// priceAmounts is an order described by price and size converted to token
+// amounts
+type priceAmounts struct {
+	makerToken  *Token
+	takerToken  *Token
+	makerAmount *big.Int
+	takerAmount *big.Int
+	// deviation is how far rounding the quote amount to base units moved the
+	// price, as a fraction of the requested price
+	deviation *big.Rat
+}
+
+// orderFromPrice converts an order to buy or sell size of the base token, at
+// a price in quote tokens per base token, to token amounts. The size must be
+// exact in base units, while the quote amount is rounded in the maker's
+// favor.
+func orderFromPrice(side string, base, quote *Token, price, size string) (*priceAmounts, error) {
+	priceValue, err := parseDecimal(price)
+	if err != nil {
+		return nil, err
+	}
+	baseAmount, err := base.BaseUnits(size)
+	if err != nil {
+		return nil, err
+	}
+	return orderFromPriceAmount(side, base, quote, priceValue, baseAmount)
+}
+
+// orderFromPriceAmount is orderFromPrice with the price already parsed and
+// the size in base units.
+func orderFromPriceAmount(side string, base, quote *Token, priceValue *big.Rat, baseAmount *big.Int) (*priceAmounts, error) {
+	if priceValue.Sign() <= 0 {
+		return nil, errors.New("price must be greater than zero")
+	}
+	if baseAmount.Sign() == 0 {
+		return nil, errors.New("size must be greater than zero")
+	}
+	// quote base units = base units * price * quote scale / base scale
+	exactQuote := new(big.Rat).SetInt(baseAmount)
+	exactQuote.Mul(exactQuote, priceValue)
+	exactQuote.Mul(exactQuote, quote.scale())
+	exactQuote.Quo(exactQuote, base.scale())
+	quoteAmount := new(big.Int).Quo(exactQuote.Num(), exactQuote.Denom())
+	result := &priceAmounts{}
+	switch side {
+	case SideSell:
+		// The maker receives the quote token, so round it up
+		if !exactQuote.IsInt() {
+			quoteAmount.Add(quoteAmount, big.NewInt(1))
+		}
+		result.makerToken, result.makerAmount = base, baseAmount
+		result.takerToken, result.takerAmount = quote, quoteAmount
+	case SideBuy:
+		// The maker pays the quote token, so round it down
+		result.makerToken, result.makerAmount = quote, quoteAmount
+		result.takerToken, result.takerAmount = base, baseAmount
+	default:
+		return nil, fmt.Errorf("side must be %v or %v, not '%v'", SideBuy, SideSell, side)
+	}
+	if quoteAmount.Sign() == 0 {
+		return nil, errors.New("quote amount rounds to zero")
+	}
+	effectivePrice := new(big.Rat).SetFrac(quoteAmount, baseAmount)
+	effectivePrice.Mul(effectivePrice, base.scale())
+	effectivePrice.Quo(effectivePrice, quote.scale())
+	result.deviation = new(big.Rat).Sub(effectivePrice, priceValue)
+	result.deviation.Abs(result.deviation)
+	result.deviation.Quo(result.deviation, priceValue)
+	return result, nil
+}
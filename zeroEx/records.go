@@ -0,0 +1,26 @@
+package zeroEx
+
+import (
+	"encoding/json"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
+	"io"
+)
+
+// writeAnnotatedOrder writes the order to outputFile with the provided fields
+// added alongside the standard order fields. The resulting records can still
+// be read by orderScanner, which ignores the extra fields.
+func writeAnnotatedOrder(order *types.Order, annotations map[string]interface{}, outputFile io.Writer) error {
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(orderBytes, &record); err != nil {
+		return err
+	}
+	for key, value := range annotations {
+		record[key] = value
+	}
+	return utils.WriteRecord(record, outputFile)
+}
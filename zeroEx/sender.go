@@ -0,0 +1,258 @@
+package zeroEx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// maxNonceRetries is the number of times a transaction will be resubmitted
+// after a nonce error before giving up
+const maxNonceRetries = 5
+
+// transactFunc submits a transaction with the provided options, such as a
+// contract binding's method.
+type transactFunc func(opts *bind.TransactOpts) (*gethTypes.Transaction, error)
+
+// TransactionBackend is the part of an Ethereum client that a
+// TransactionSender uses. Transactions themselves are sent by the transactFunc,
+// usually through a contract binding. *ethclient.Client implements it.
+type TransactionBackend interface {
+	bind.DeployBackend
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+}
+
+// accountNonce tracks the next nonce for an account. The mutex is held while
+// a transaction is being submitted so that nonces are handed out in order.
+type accountNonce struct {
+	mutex sync.Mutex
+	nonce *uint64
+}
+
+// TransactionSender submits transactions for on-chain commands. Nonces are
+// assigned locally, starting from each account's pending nonce, so several
+// transactions from the same account can be in flight at once.
+//
+// Transactions are signed with EIP155 replay protection for the sender's
+// chain ID. If GasPricer is nil the node's suggested gas price is used, and if
+// GasLimit is nil the gas limit is estimated.
+//
+// If DryRun is set, transactions are built and their gas estimated, but they
+// are not signed or sent, and no receipts are returned.
+type TransactionSender struct {
+	GasPricer  GasPricer
+	GasLimit   *big.Int
+	DryRun     bool
+	conn       TransactionBackend
+	signer     gethTypes.Signer
+	mutex      sync.Mutex
+	nonces     map[common.Address]*accountNonce
+	dryRunCost *big.Int
+	dryRunTxs  int
+}
+
+func NewTransactionSender(conn TransactionBackend, chainID *big.Int) *TransactionSender {
+	return &TransactionSender{
+		GasPricer:  &nodeGasPricer{conn},
+		conn:       conn,
+		signer:     gethTypes.NewEIP155Signer(chainID),
+		nonces:     make(map[common.Address]*accountNonce),
+		dryRunCost: new(big.Int),
+	}
+}
+
+// transactor returns TransactOpts that sign with key using the sender's
+// EIP155 signer. The bind package passes a Homestead signer, which would
+// leave the chain ID out of the signature, so it is replaced.
+func (sender *TransactionSender) transactor(key *ecdsa.PrivateKey) *bind.TransactOpts {
+	opts := bind.NewKeyedTransactor(key)
+	sign := opts.Signer
+	opts.Signer = func(_ gethTypes.Signer, address common.Address, transaction *gethTypes.Transaction) (*gethTypes.Transaction, error) {
+		return sign(sender.signer, address, transaction)
+	}
+	return opts
+}
+
+func (sender *TransactionSender) account(address common.Address) *accountNonce {
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	if _, ok := sender.nonces[address]; !ok {
+		sender.nonces[address] = &accountNonce{}
+	}
+	return sender.nonces[address]
+}
+
+// isNonceTooLow reports whether the node rejected a transaction because its
+// nonce has already been used by a mined transaction
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// isNonceTaken reports whether the node rejected a transaction because a
+// different pending transaction already has its nonce
+func isNonceTaken(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "replacement transaction underpriced")
+}
+
+// isKnownTransaction reports whether the node rejected a transaction because
+// the same signed transaction is already in its pool
+func isKnownTransaction(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "known transaction")
+}
+
+// options sets the nonce, gas limit and gas price of opts for a transaction
+func (sender *TransactionSender) options(opts *bind.TransactOpts, nonce uint64) (*bind.TransactOpts, error) {
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	opts.GasLimit = sender.GasLimit
+	if sender.GasPricer != nil {
+		gasPrice, err := sender.GasPricer.GasPrice()
+		if err != nil {
+			return nil, err
+		}
+		opts.GasPrice = gasPrice
+	}
+	return opts, nil
+}
+
+// Transact submits a transaction signed by key, without waiting for it to be
+// mined. If the node rejects the transaction's nonce, the nonce is corrected
+// and the transaction resubmitted. If the node already has the transaction,
+// it is treated as sent rather than being sent again with another nonce.
+func (sender *TransactionSender) Transact(key *ecdsa.PrivateKey, transact transactFunc) (*gethTypes.Transaction, error) {
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	if sender.DryRun {
+		return sender.estimate(key, transact)
+	}
+	account := sender.account(address)
+	account.mutex.Lock()
+	defer account.mutex.Unlock()
+	for attempt := 0; ; attempt++ {
+		if account.nonce == nil {
+			nonce, err := sender.conn.PendingNonceAt(context.Background(), address)
+			if err != nil {
+				return nil, err
+			}
+			account.nonce = &nonce
+		}
+		opts, err := sender.options(sender.transactor(key), *account.nonce)
+		if err != nil {
+			return nil, err
+		}
+		var signed *gethTypes.Transaction
+		sign := opts.Signer
+		opts.Signer = func(signer gethTypes.Signer, address common.Address, transaction *gethTypes.Transaction) (*gethTypes.Transaction, error) {
+			var err error
+			signed, err = sign(signer, address, transaction)
+			return signed, err
+		}
+		transaction, err := transact(opts)
+		if err != nil && signed != nil && isKnownTransaction(err) {
+			log.Printf("Transaction %v is already pending", signed.Hash().Hex())
+			transaction, err = signed, nil
+		}
+		if err == nil {
+			*account.nonce++
+			log.Printf("Sent transaction %v from %v with nonce %v, gas price %v wei, gas limit %v", transaction.Hash().Hex(), address.Hex(), transaction.Nonce(), transaction.GasPrice(), transaction.Gas())
+			return transaction, nil
+		}
+		if attempt >= maxNonceRetries {
+			return nil, err
+		}
+		if isNonceTooLow(err) {
+			log.Printf("Nonce %v too low for %v, refreshing from pending nonce", *account.nonce, address.Hex())
+			account.nonce = nil
+		} else if isNonceTaken(err) {
+			log.Printf("Nonce %v already pending for %v, trying the next nonce", *account.nonce, address.Hex())
+			*account.nonce++
+		} else {
+			return nil, err
+		}
+	}
+}
+
+// errDryRun stops the bind package from sending a dry run's transaction
+var errDryRun = errors.New("dry run")
+
+// estimate builds the transaction transact would send, with the same options
+// Transact would use, and returns it unsigned instead of sending it. Gas is
+// only estimated if GasLimit is nil.
+func (sender *TransactionSender) estimate(key *ecdsa.PrivateKey, transact transactFunc) (*gethTypes.Transaction, error) {
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := sender.conn.PendingNonceAt(context.Background(), address)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := sender.options(bind.NewKeyedTransactor(key), nonce)
+	if err != nil {
+		return nil, err
+	}
+	var transaction *gethTypes.Transaction
+	opts.Signer = func(_ gethTypes.Signer, _ common.Address, rawTransaction *gethTypes.Transaction) (*gethTypes.Transaction, error) {
+		transaction = rawTransaction
+		return nil, errDryRun
+	}
+	if _, err := transact(opts); err != errDryRun {
+		return nil, err
+	}
+	cost := transactionCost(transaction)
+	sender.mutex.Lock()
+	sender.dryRunCost.Add(sender.dryRunCost, cost)
+	sender.dryRunTxs++
+	sender.mutex.Unlock()
+	log.Printf("Would send transaction to %v from %v, gas limit %v at gas price %v wei, costing %v ETH", transaction.To().Hex(), opts.From.Hex(), transaction.Gas(), transaction.GasPrice(), weiToEther(cost))
+	return transaction, nil
+}
+
+// transactionCost is the most a transaction can cost in wei
+func transactionCost(transaction *gethTypes.Transaction) *big.Int {
+	return new(big.Int).Mul(transaction.Gas(), transaction.GasPrice())
+}
+
+func weiToEther(wei *big.Int) string {
+	return new(big.Rat).SetFrac(wei, big.NewInt(1000000000000000000)).FloatString(18)
+}
+
+// dryRunAnnotations describes a transaction built in dry run mode, for
+// adding to an output record
+func dryRunAnnotations(transaction *gethTypes.Transaction) map[string]interface{} {
+	return map[string]interface{}{
+		"to":            transaction.To().Hex(),
+		"data":          hexutil.Encode(transaction.Data()),
+		"estimatedGas":  transaction.Gas().String(),
+		"gasPrice":      transaction.GasPrice().String(),
+		"estimatedCost": transactionCost(transaction).String(),
+	}
+}
+
+// LogDryRunCost logs the total estimated cost of the transactions built in
+// dry run mode
+func (sender *TransactionSender) LogDryRunCost() {
+	if !sender.DryRun {
+		return
+	}
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	log.Printf("Dry run: %v transactions would cost up to %v ETH", sender.dryRunTxs, weiToEther(sender.dryRunCost))
+}
+
+// TransactAndWait submits a transaction as Transact does, then waits for it
+// to be mined. The transaction is returned along with any error so that
+// callers can report its hash. In dry run mode the receipt is nil.
+func (sender *TransactionSender) TransactAndWait(key *ecdsa.PrivateKey, transact transactFunc) (*gethTypes.Transaction, *gethTypes.Receipt, error) {
+	transaction, err := sender.Transact(key, transact)
+	if err != nil || sender.DryRun {
+		return transaction, nil, err
+	}
+	receipt, err := bind.WaitMined(context.Background(), sender.conn, transaction)
+	return transaction, receipt, err
+}
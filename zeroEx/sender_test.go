@@ -0,0 +1,189 @@
+package zeroEx_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/notegio/massive/zeroEx"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// fakeNode accepts transactions the way a node's transaction pool does,
+// rejecting nonces that have been mined or are already pending
+type fakeNode struct {
+	mutex sync.Mutex
+	// staleNonces are returned by PendingNonceAt before the real pending
+	// nonce, as when another wallet has sent transactions from the account
+	staleNonces []uint64
+	mined       uint64
+	pending     map[uint64]bool
+	// failures are returned by SendTransaction before anything else
+	failures []error
+	// known is the number of transactions to pool while answering "known
+	// transaction", as when a send is retried after the node accepted it
+	known int
+}
+
+func (node *fakeNode) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	if len(node.staleNonces) > 0 {
+		nonce := node.staleNonces[0]
+		node.staleNonces = node.staleNonces[1:]
+		return nonce, nil
+	}
+	nonce := node.mined
+	for node.pending[nonce] {
+		nonce++
+	}
+	return nonce, nil
+}
+
+func (node *fakeNode) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (node *fakeNode) TransactionReceipt(ctx context.Context, txHash common.Hash) (*gethTypes.Receipt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (node *fakeNode) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (node *fakeNode) SendTransaction(ctx context.Context, transaction *gethTypes.Transaction) error {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	if len(node.failures) > 0 {
+		err := node.failures[0]
+		node.failures = node.failures[1:]
+		return err
+	}
+	if transaction.Nonce() < node.mined {
+		return errors.New("nonce too low")
+	}
+	if node.pending[transaction.Nonce()] {
+		return errors.New("replacement transaction underpriced")
+	}
+	node.pending[transaction.Nonce()] = true
+	if node.known > 0 {
+		node.known--
+		return errors.New("known transaction: " + transaction.Hash().Hex())
+	}
+	return nil
+}
+
+// send signs and sends a transaction with opts, as a contract binding would.
+// The gas estimate is always 21000.
+func (node *fakeNode) send(opts *bind.TransactOpts) (*gethTypes.Transaction, error) {
+	gasLimit := opts.GasLimit
+	if gasLimit == nil {
+		gasLimit = big.NewInt(21000)
+	}
+	transaction := gethTypes.NewTransaction(opts.Nonce.Uint64(), common.Address{}, new(big.Int), gasLimit, opts.GasPrice, nil)
+	signed, err := opts.Signer(nil, opts.From, transaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.SendTransaction(opts.Context, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+func TestTransactionSenderNonces(t *testing.T) {
+	items := []struct {
+		name        string
+		staleNonces []uint64
+		mined       uint64
+		pending     []uint64
+		failures    []error
+		known       int
+		nonces      []uint64
+		err         bool
+	}{
+		{name: "fresh account", nonces: []uint64{0, 1, 2, 3, 4}},
+		{name: "pending nonce", mined: 3, nonces: []uint64{3, 4, 5, 6, 7}},
+		{name: "nonce too low", staleNonces: []uint64{1}, mined: 3, nonces: []uint64{3, 4, 5, 6, 7}},
+		{name: "replacement underpriced", staleNonces: []uint64{0}, pending: []uint64{0, 1}, nonces: []uint64{2, 3, 4, 5, 6}},
+		{name: "known transaction", known: 1, nonces: []uint64{0, 1, 2, 3, 4}},
+		{name: "other error", failures: []error{errors.New("insufficient funds for gas * price + value")}, nonces: []uint64{0, 1, 2, 3}, err: true},
+	}
+	for _, item := range items {
+		node := &fakeNode{staleNonces: item.staleNonces, mined: item.mined, pending: make(map[uint64]bool), failures: item.failures, known: item.known}
+		for _, nonce := range item.pending {
+			node.pending[nonce] = true
+		}
+		key, _ := crypto.GenerateKey()
+		sender := zeroEx.NewTransactionSender(node, big.NewInt(1))
+		nonces := []uint64{}
+		errs := 0
+		mutex := &sync.Mutex{}
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				transaction, err := sender.Transact(key, node.send)
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					errs++
+					return
+				}
+				nonces = append(nonces, transaction.Nonce())
+			}()
+		}
+		wg.Wait()
+		if sent := len(node.pending) - len(item.pending); sent != len(nonces) {
+			t.Errorf("%v: expected %v transactions in the pool, got %v", item.name, len(nonces), sent)
+		}
+		if item.err != (errs == 1) || errs > 1 {
+			t.Errorf("%v: unexpected number of errors: %v", item.name, errs)
+		}
+		sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+		if len(nonces) != len(item.nonces) {
+			t.Fatalf("%v: expected nonces %v, got %v", item.name, item.nonces, nonces)
+		}
+		for i := range nonces {
+			if nonces[i] != item.nonces[i] {
+				t.Errorf("%v: expected nonces %v, got %v", item.name, item.nonces, nonces)
+				break
+			}
+		}
+	}
+}
+
+func TestTransactionSenderDryRun(t *testing.T) {
+	items := []struct {
+		name     string
+		gasLimit *big.Int
+		gas      int64
+	}{
+		{"estimated", nil, 21000},
+		{"gas limit", big.NewInt(100000), 100000},
+	}
+	for _, item := range items {
+		node := &fakeNode{pending: make(map[uint64]bool)}
+		key, _ := crypto.GenerateKey()
+		sender := zeroEx.NewTransactionSender(node, big.NewInt(1))
+		sender.DryRun = true
+		sender.GasLimit = item.gasLimit
+		transaction, err := sender.Transact(key, node.send)
+		if err != nil {
+			t.Fatalf("%v: %v", item.name, err.Error())
+		}
+		if transaction.Gas().Int64() != item.gas {
+			t.Errorf("%v: expected gas %v, got %v", item.name, item.gas, transaction.Gas())
+		}
+		if len(node.pending) != 0 {
+			t.Errorf("%v: expected nothing to be sent", item.name)
+		}
+	}
+}
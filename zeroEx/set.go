@@ -0,0 +1,115 @@
+package zeroEx
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+type set struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+}
+
+func (p *set) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *set) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*set) Name() string     { return "set" }
+func (*set) Synopsis() string { return "Set order fields to computed values" }
+func (*set) Usage() string {
+	return `msv 0x set [--input FILE] [--output FILE] FIELD=EXPRESSION...:
+  Set fields of each order to the value of an expression, using the same
+  fields, values and operators as "msv 0x filter". For example:
+
+    msv 0x set taker=0x324454186bb728a3ea55750e0618ff1b18ce6cf8 'takerTokenAmount = makerTokenAmount * 3 / 2'
+
+  Assignments are applied in order, so later expressions see the values set
+  by earlier ones. Arithmetic is exact, and setting an amount to a value that
+  isn't a whole number is an error unless it is rounded with floor() or
+  ceil(). Addresses must be 20 bytes of hex.
+
+  Setting fields that are part of the order hash invalidates any existing
+  signature, so orders should be signed after they are modified.
+`
+}
+
+func (p *set) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+}
+
+func (p *set) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() == 0 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	return SetMain(p.inputFile, p.outputFile, f.Args())
+}
+
+type assignment struct {
+	field string
+	value expr
+}
+
+// parseAssignment parses "field=expression"
+func parseAssignment(source string) (*assignment, error) {
+	parts := strings.SplitN(source, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected FIELD=EXPRESSION, got '%v'", source)
+	}
+	field := strings.TrimSpace(parts[0])
+	if _, ok := orderFields[field]; !ok {
+		if _, ok := derivedValues[field]; ok {
+			return nil, fmt.Errorf("%v is derived from the order and cannot be set", field)
+		}
+		if command, ok := recordValues[field]; ok {
+			return nil, fmt.Errorf("%v is added by %v and cannot be set", field, command)
+		}
+		return nil, fmt.Errorf("unknown field '%v'", field)
+	}
+	value, err := parseExpr(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", field, err.Error())
+	}
+	return &assignment{field, value}, nil
+}
+
+// SetMain applies each assignment, in order, to every order
+func SetMain(inputFile io.Reader, outputFile io.Writer, assignments []string) subcommands.ExitStatus {
+	parsed := make([]*assignment, len(assignments))
+	for i, source := range assignments {
+		var err error
+		if parsed[i], err = parseAssignment(source); err != nil {
+			log.Printf("Error parsing assignment: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	for order := range orderScanner(inputFile) {
+		for _, assignment := range parsed {
+			value, err := evalOrder(assignment.value, order)
+			if err == nil {
+				err = orderFields[assignment.field].set(order, value)
+			}
+			if err != nil {
+				log.Printf("Error setting %v on order %#x: %v", assignment.field, order.Hash(), err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+		utils.WriteRecord(order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
@@ -2,11 +2,11 @@ package zeroEx
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"flag"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/crypto"
+	gethTypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/utils"
 	orCommon "github.com/notegio/openrelay/common"
@@ -27,6 +27,9 @@ type setAllowance struct {
 	inputFile      *os.File
 	outputFile     *os.File
 	unlimited      bool
+	revoke         bool
+	keyOpts        utils.KeyOptions
+	txOpts         transactionOptions
 }
 
 func (p *setAllowance) FileNames() (string, string) {
@@ -42,22 +45,38 @@ func (*setAllowance) Synopsis() string {
 	return "Set the 0x Exchange Address on each order for the specified network"
 }
 func (*setAllowance) Usage() string {
-	return `msv 0x setAllowance [--unlimited] [--input FILE] [--output FILE] ETHEREUM_RPC_URL KEY_FILE:
+	return `msv 0x setAllowance [--unlimited | --revoke] [--dry-run] [--password-file FILE | --password-env VAR] [--gas-price WEI | --gas-price-strategy STRATEGY] [--gas-limit GAS] [--max-gas-price WEI] [--chain-id ID] [--input FILE] [--output FILE] ETHEREUM_RPC_URL KEY_FILE:
   Set allowances on the target Ethereum host using the specified key for any
 	orders in the input file. Replays the orders on the output file after the
 	approvals have been confirmed. Orders may output in a different order than
 	input, depending on when the approvals are confirmed.
 
 	If the --unlimited flag is provided and the current allowance is below 2^255,
-	allowances will be set to 2^256 - 1. Otherwise allowances will be increased
-	by the amount in the order.
+	allowances will be set to 2^256 - 1. Otherwise the maker token amounts and
+	maker fees each maker needs for each token are summed across the whole
+	input, and any allowance below that total is raised to exactly the total.
+	In this mode orders are only replayed once all of the input has been read.
+
+	If the --revoke flag is provided, allowances for every maker token and fee
+	token in the input are set back to zero.
+
+	With --dry-run, allowances are still checked, but instead of sending
+	approvals and replaying the orders, each approval that would be sent is
+	written to the output with its calldata, estimated gas and cost.
+
+	KEY_FILE may be a plaintext private key, an encrypted geth keystore file,
+	or a keystore directory, in which case the key matching each order's maker
+	is used.
 `
 }
 
 func (p *setAllowance) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
 	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
-	f.BoolVar(&p.unlimited, "unlimited", false, "Set unlimited allowances for ")
+	f.BoolVar(&p.unlimited, "unlimited", false, "Set unlimited allowances for each maker and token")
+	f.BoolVar(&p.revoke, "revoke", false, "Set allowances to zero for each maker and token")
+	p.keyOpts.SetFlags(f)
+	p.txOpts.SetFlags(f)
 }
 
 func (p *setAllowance) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -65,12 +84,23 @@ func (p *setAllowance) Execute(_ context.Context, f *flag.FlagSet, _ ...interfac
 		os.Stderr.WriteString(p.Usage())
 		return subcommands.ExitUsageError
 	}
+	if p.unlimited && p.revoke {
+		log.Printf("Specify at most one of --unlimited or --revoke")
+		return subcommands.ExitUsageError
+	}
 	utils.SetIO(p)
-	conn, err := ethclient.Dial(f.Arg(0))
+	mode := AllowanceExact
+	if p.unlimited {
+		mode = AllowanceUnlimited
+	} else if p.revoke {
+		mode = AllowanceRevoke
+	}
+	client, err := rpc.Dial(f.Arg(0))
 	if err != nil {
 		log.Printf("Error establishing Ethereum connection: %v", err.Error())
 		return subcommands.ExitFailure
 	}
+	conn := ethclient.NewClient(client)
 	tokenProxyCfg, err := config.NewRpcTokenProxy(f.Arg(0))
 	if err != nil {
 		log.Printf("Error setting up TokenProxy config: %v", err.Error())
@@ -81,7 +111,7 @@ func (p *setAllowance) Execute(_ context.Context, f *flag.FlagSet, _ ...interfac
 		log.Printf("Error setting up FeeToken config: %v", err.Error())
 		return subcommands.ExitFailure
 	}
-	privKey, err := crypto.LoadECDSA(f.Arg(1))
+	keys, err := p.keyOpts.Load(f.Arg(1))
 	if err != nil {
 		log.Printf("Error loading key: %v", err.Error())
 		return subcommands.ExitFailure
@@ -91,12 +121,34 @@ func (p *setAllowance) Execute(_ context.Context, f *flag.FlagSet, _ ...interfac
 		log.Printf("Error initializing balanceChecker: %v", err.Error())
 		return subcommands.ExitFailure
 	}
-	return SetAllowanceMain(p.inputFile, p.outputFile, conn, privKey, p.unlimited, tokenProxyCfg, feeTokenCfg, balanceChecker)
+	sender, err := p.txOpts.Sender(client, conn)
+	if err != nil {
+		log.Printf("Error configuring transactions: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return SetAllowanceMain(p.inputFile, p.outputFile, conn, sender, keys, mode, tokenProxyCfg, feeTokenCfg, balanceChecker)
 }
 
-func SetAllowanceMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, key *ecdsa.PrivateKey, unlimited bool, tokenProxyCfg config.TokenProxy, feeTokenCfg config.FeeToken, balanceChecker funds.BalanceChecker) subcommands.ExitStatus {
-	if !unlimited {
-		log.Printf("Currently only unlimited allowances are supported. Add the '--unlimited' to use this tool.")
+// unlimitedAllowance is the largest allowance a token can hold
+var unlimitedAllowance = new(big.Int).Sub(new(big.Int).Exp(big.NewInt(2), big.NewInt(256), nil), big.NewInt(1))
+
+// The modes SetAllowanceMain can set allowances in
+const (
+	AllowanceExact     = "exact"
+	AllowanceUnlimited = "unlimited"
+	AllowanceRevoke    = "revoke"
+)
+
+func SetAllowanceMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, sender *TransactionSender, keys utils.KeyLookup, mode string, tokenProxyCfg config.TokenProxy, feeTokenCfg config.FeeToken, balanceChecker funds.BalanceChecker) subcommands.ExitStatus {
+	switch mode {
+	case AllowanceUnlimited:
+		if sender.DryRun {
+			return setBoundedAllowances(inputFile, outputFile, conn, sender, keys, mode, tokenProxyCfg, feeTokenCfg, balanceChecker)
+		}
+	case AllowanceExact, AllowanceRevoke:
+		return setBoundedAllowances(inputFile, outputFile, conn, sender, keys, mode, tokenProxyCfg, feeTokenCfg, balanceChecker)
+	default:
+		log.Printf("Unknown allowance mode: %v", mode)
 		return subcommands.ExitFailure
 	}
 	allowanceFutures := make(map[types.Address]map[types.Address]*allowanceFuture)
@@ -130,7 +182,7 @@ func SetAllowanceMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient
 				nil,
 				make(chan bool),
 			}
-			go allowanceFutures[*order.Maker][*order.MakerToken].Populate(order.Maker, order.MakerToken, order, balanceChecker, tokenProxyCfg, conn, key)
+			go allowanceFutures[*order.Maker][*order.MakerToken].Populate(order.Maker, order.MakerToken, order, balanceChecker, tokenProxyCfg, conn, sender, keys)
 		}
 		_, ok = allowanceFutures[*order.Maker][*feeTokenAddress]
 		if !ok {
@@ -139,7 +191,7 @@ func SetAllowanceMain(inputFile io.Reader, outputFile io.Writer, conn *ethclient
 				nil,
 				make(chan bool),
 			}
-			go allowanceFutures[*order.Maker][*feeTokenAddress].Populate(order.Maker, feeTokenAddress, order, balanceChecker, tokenProxyCfg, conn, key)
+			go allowanceFutures[*order.Maker][*feeTokenAddress].Populate(order.Maker, feeTokenAddress, order, balanceChecker, tokenProxyCfg, conn, sender, keys)
 		}
 		wg.Add(1)
 		go func(order *types.Order) {
@@ -176,8 +228,7 @@ func (future *allowanceFuture) Get() (*big.Int, error) {
 	return future.allowance, future.err
 }
 
-func (future *allowanceFuture) Populate(makerAddress, tokenAddress *types.Address, order *types.Order, balanceChecker funds.BalanceChecker, tokenProxyCfg config.TokenProxy, conn *ethclient.Client, key *ecdsa.PrivateKey) {
-	unlimitedAllowance := new(big.Int).Sub(new(big.Int).Exp(big.NewInt(2), big.NewInt(256), nil), big.NewInt(1))
+func (future *allowanceFuture) Populate(makerAddress, tokenAddress *types.Address, order *types.Order, balanceChecker funds.BalanceChecker, tokenProxyCfg config.TokenProxy, conn *ethclient.Client, sender *TransactionSender, keys utils.KeyLookup) {
 	tokenProxyAddress, err := tokenProxyCfg.Get(order)
 	allowance, err := balanceChecker.GetAllowance(tokenAddress, makerAddress, tokenProxyAddress)
 	if err != nil {
@@ -186,22 +237,7 @@ func (future *allowanceFuture) Populate(makerAddress, tokenAddress *types.Addres
 		return
 	}
 	if new(big.Int).Rsh(unlimitedAllowance, 2).Cmp(allowance) > 0 {
-		token, err := tokenModule.NewToken(orCommon.ToGethAddress(tokenAddress), conn)
-		if err != nil {
-			future.err = err
-			close(future.channel)
-			return
-		}
-		transactOpt := bind.NewKeyedTransactor(key)
-		// TODO: Allow configuration of gas price
-		transaction, err := token.TokenTransactor.Approve(transactOpt, orCommon.ToGethAddress(tokenProxyAddress), unlimitedAllowance)
-		if err != nil {
-			future.err = err
-			close(future.channel)
-			return
-		}
-		_, err = bind.WaitMined(context.Background(), conn, transaction)
-		if err != nil {
+		if _, err := approveAllowance(conn, sender, keys, makerAddress, tokenAddress, tokenProxyAddress, unlimitedAllowance); err != nil {
 			future.err = err
 			close(future.channel)
 			return
@@ -212,3 +248,134 @@ func (future *allowanceFuture) Populate(makerAddress, tokenAddress *types.Addres
 	close(future.channel)
 
 }
+
+// approveAllowance sets the maker's allowance for the token proxy to amount,
+// and waits for the approval to be mined. In dry run mode the approval is
+// returned without being sent.
+func approveAllowance(conn *ethclient.Client, sender *TransactionSender, keys utils.KeyLookup, makerAddress, tokenAddress, tokenProxyAddress *types.Address, amount *big.Int) (*gethTypes.Transaction, error) {
+	token, err := tokenModule.NewToken(orCommon.ToGethAddress(tokenAddress), conn)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keys.Get(orCommon.ToGethAddress(makerAddress))
+	if err != nil {
+		return nil, err
+	}
+	transaction, _, err := sender.TransactAndWait(key, func(opts *bind.TransactOpts) (*gethTypes.Transaction, error) {
+		return token.TokenTransactor.Approve(opts, orCommon.ToGethAddress(tokenProxyAddress), amount)
+	})
+	return transaction, err
+}
+
+// allowanceKey identifies an allowance a maker has given a token proxy
+type allowanceKey struct {
+	maker      types.Address
+	token      types.Address
+	tokenProxy types.Address
+}
+
+// allowanceResult is the outcome of setting one allowance
+type allowanceResult struct {
+	key         allowanceKey
+	allowance   *big.Int
+	amount      *big.Int
+	transaction *gethTypes.Transaction
+	err         error
+}
+
+// annotations describes the approval a dry run would send
+func (result *allowanceResult) annotations() map[string]interface{} {
+	annotations := dryRunAnnotations(result.transaction)
+	annotations["maker"] = result.key.maker.String()
+	annotations["token"] = result.key.token.String()
+	annotations["spender"] = result.key.tokenProxy.String()
+	annotations["currentAllowance"] = result.allowance.String()
+	annotations["allowance"] = result.amount.String()
+	return annotations
+}
+
+// setBoundedAllowances reads the whole input, then raises each allowance to
+// the total the input's orders need, sets it to unlimited, or revokes it,
+// depending on mode. Orders are replayed once every allowance has been set.
+// In dry run mode the approvals are written to the output instead of the
+// orders.
+func setBoundedAllowances(inputFile io.Reader, outputFile io.Writer, conn *ethclient.Client, sender *TransactionSender, keys utils.KeyLookup, mode string, tokenProxyCfg config.TokenProxy, feeTokenCfg config.FeeToken, balanceChecker funds.BalanceChecker) subcommands.ExitStatus {
+	orders := []*types.Order{}
+	required := make(map[allowanceKey]*big.Int)
+	allowanceKeys := []allowanceKey{}
+	addRequired := func(key allowanceKey, amount *types.Uint256) {
+		if _, ok := required[key]; !ok {
+			required[key] = new(big.Int)
+			allowanceKeys = append(allowanceKeys, key)
+		}
+		required[key].Add(required[key], new(big.Int).SetBytes(amount[:]))
+	}
+	for order := range orderScanner(inputFile) {
+		feeTokenAddress, err := feeTokenCfg.Get(order)
+		if err != nil {
+			log.Printf("Error getting fee token for exchange %v: %v", order.ExchangeAddress, err.Error())
+			return subcommands.ExitFailure
+		}
+		tokenProxyAddress, err := tokenProxyCfg.Get(order)
+		if err != nil {
+			log.Printf("Error getting token proxy for exchange %v: %v", order.ExchangeAddress, err.Error())
+			return subcommands.ExitFailure
+		}
+		addRequired(allowanceKey{*order.Maker, *order.MakerToken, *tokenProxyAddress}, order.MakerTokenAmount)
+		addRequired(allowanceKey{*order.Maker, *feeTokenAddress, *tokenProxyAddress}, order.MakerFee)
+		orders = append(orders, order)
+	}
+	results := make(chan *allowanceResult)
+	for _, key := range allowanceKeys {
+		go func(key allowanceKey, amount *big.Int) {
+			result := &allowanceResult{key: key}
+			defer func() { results <- result }()
+			result.allowance, result.err = balanceChecker.GetAllowance(&key.token, &key.maker, &key.tokenProxy)
+			if result.err != nil {
+				return
+			}
+			switch mode {
+			case AllowanceRevoke:
+				if result.allowance.Sign() == 0 {
+					return
+				}
+				result.amount = new(big.Int)
+				log.Printf("Revoking allowance of %v for maker %v on token %v", result.allowance, &key.maker, &key.token)
+			case AllowanceUnlimited:
+				if new(big.Int).Rsh(unlimitedAllowance, 2).Cmp(result.allowance) <= 0 {
+					return
+				}
+				result.amount = unlimitedAllowance
+				log.Printf("Setting unlimited allowance for maker %v on token %v", &key.maker, &key.token)
+			default:
+				if result.allowance.Cmp(amount) >= 0 {
+					return
+				}
+				result.amount = amount
+				log.Printf("Raising allowance for maker %v on token %v by %v to %v", &key.maker, &key.token, new(big.Int).Sub(amount, result.allowance), amount)
+			}
+			result.transaction, result.err = approveAllowance(conn, sender, keys, &key.maker, &key.token, &key.tokenProxy, result.amount)
+		}(key, required[key])
+	}
+	failed := 0
+	for range allowanceKeys {
+		result := <-results
+		if result.err != nil {
+			log.Printf("Error getting / setting allowance %v", result.err.Error())
+			failed++
+		} else if sender.DryRun && result.transaction != nil {
+			utils.WriteRecord(result.annotations(), outputFile)
+		}
+	}
+	if failed > 0 {
+		return subcommands.ExitFailure
+	}
+	if sender.DryRun {
+		sender.LogDryRunCost()
+		return subcommands.ExitSuccess
+	}
+	for _, order := range orders {
+		utils.WriteRecord(order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,62 @@
+package zeroEx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+)
+
+func setTestOrder(t *testing.T) *bytes.Buffer {
+	order := &types.Order{}
+	order.Initialize()
+	copy(order.MakerTokenAmount[:], abi.U256(big.NewInt(1001)))
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return bytes.NewBuffer(append(data, '\n'))
+}
+
+func TestSetFields(t *testing.T) {
+	outputBuffer := &bytes.Buffer{}
+	status := zeroEx.SetMain(setTestOrder(t), outputBuffer, []string{
+		"taker=0x324454186bb728a3ea55750e0618ff1b18ce6cf8",
+		"takerTokenAmount = floor(makerTokenAmount * 3 / 2)",
+		"makerFee=takerTokenAmount - 1",
+	})
+	if status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	order := &types.Order{}
+	if err := json.Unmarshal(outputBuffer.Bytes(), order); err != nil {
+		t.Fatal(err.Error())
+	}
+	if taker := order.Taker.String(); taker != "0x324454186bb728a3ea55750e0618ff1b18ce6cf8" {
+		t.Errorf("Unexpected taker %v", taker)
+	}
+	if takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:]).String(); takerTokenAmount != "1501" {
+		t.Errorf("Unexpected takerTokenAmount %v", takerTokenAmount)
+	}
+	if makerFee := new(big.Int).SetBytes(order.MakerFee[:]).String(); makerFee != "1500" {
+		t.Errorf("Unexpected makerFee %v", makerFee)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	for _, assignment := range []string{
+		"taker=0x3244",
+		"takerTokenAmount=makerTokenAmount * 3 / 2",
+		"makerFee=0 - 1",
+		"price=1",
+		"takerTokenAmount",
+	} {
+		if status := zeroEx.SetMain(setTestOrder(t), &bytes.Buffer{}, []string{assignment}); status != subcommands.ExitFailure {
+			t.Errorf("%v: expected failure, got %v", assignment, status)
+		}
+	}
+}
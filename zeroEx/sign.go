@@ -5,9 +5,12 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"flag"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
 	"io"
 	"log"
 	"os"
@@ -21,6 +24,7 @@ type signOrder struct {
 	outputFile        *os.File
 	errOnMismatch     bool
 	replaceOnMismatch bool
+	keyOpts           utils.KeyOptions
 }
 
 func (p *signOrder) FileNames() (string, string) {
@@ -34,8 +38,14 @@ func (p *signOrder) SetIOFiles(inputFile, outputFile *os.File) {
 func (*signOrder) Name() string     { return "sign" }
 func (*signOrder) Synopsis() string { return "Add a signature to an order" }
 func (*signOrder) Usage() string {
-	return `msv 0x sign [--input FILE] [--output FILE] KEYFILE:
-  Sign the 0x order
+	return `msv 0x sign [--password-file FILE | --password-env VAR] [--input FILE] [--output FILE] KEYFILE [KEYFILE...]:
+  Sign the 0x order. KEYFILE may be a plaintext private key or an encrypted
+  geth keystore file.
+
+  If several key files or a keystore directory are provided, the keys are
+  indexed by address and each order is signed with the key matching its
+  maker address. Orders with no matching key are logged and pass through
+  unsigned, and the number of orders signed by each key is logged when done.
 `
 }
 
@@ -44,21 +54,55 @@ func (p *signOrder) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
 	f.BoolVar(&p.errOnMismatch, "err-on-mismatch", false, "Exit with a non-zero exit code if an order's Maker does not match the provided key")
 	f.BoolVar(&p.replaceOnMismatch, "replace-on-mismatch", false, "Replace the maker address if an order's maker does not match the provided key. If the maker does not match the provided key and this flag is not set, the order will pass through unsigned.")
+	p.keyOpts.SetFlags(f)
 }
 
 func (p *signOrder) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	if f.NArg() != 1 {
+	if f.NArg() < 1 {
 		os.Stderr.WriteString(p.Usage())
 		return subcommands.ExitUsageError
 	}
 	utils.SetIO(p)
-	privKey, err := crypto.LoadECDSA(f.Arg(0))
+	if info, err := os.Stat(f.Arg(0)); f.NArg() > 1 || (err == nil && info.IsDir()) {
+		if p.replaceOnMismatch {
+			log.Printf("--replace-on-mismatch cannot be used with multiple keys")
+			return subcommands.ExitUsageError
+		}
+		keys := utils.NewKeyRing(&p.keyOpts)
+		for _, path := range f.Args() {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				err = keys.AddDirectory(path)
+			} else {
+				_, err = keys.AddFile(path)
+			}
+			if err != nil {
+				log.Printf("Error loading keys: %v", err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+		return SignOrderKeysMain(p.inputFile, p.outputFile, keys, p.errOnMismatch)
+	}
+	privKey, err := p.keyOpts.LoadKey(f.Arg(0))
 	if err != nil {
 		log.Printf("Error loading key: %v", err.Error())
 		return subcommands.ExitFailure
 	}
 	return SignOrderMain(p.inputFile, p.outputFile, privKey, p.errOnMismatch, p.replaceOnMismatch)
 }
+
+// signOrderWithKey sets the order's signature using key
+func signOrderWithKey(order *types.Order, key *ecdsa.PrivateKey) {
+	copy(order.Signature.Hash[:], order.Hash())
+
+	hashedBytes := append([]byte("\x19Ethereum Signed Message:\n32"), order.Signature.Hash[:]...)
+	signedBytes := crypto.Keccak256(hashedBytes)
+
+	sig, _ := crypto.Sign(signedBytes, key)
+	copy(order.Signature.R[:], sig[0:32])
+	copy(order.Signature.S[:], sig[32:64])
+	order.Signature.V = sig[64] + 27
+}
+
 func SignOrderMain(inputFile io.Reader, outputFile io.Writer, key *ecdsa.PrivateKey, errOnMismatch, replaceOnMismatch bool) subcommands.ExitStatus {
 	if errOnMismatch && replaceOnMismatch {
 		log.Printf("Specify at most one of --err-on-mismatch or --replace-on-mismatch")
@@ -76,16 +120,43 @@ func SignOrderMain(inputFile io.Reader, outputFile io.Writer, key *ecdsa.Private
 			}
 		}
 		copy(order.Maker[:], address[:])
-		copy(order.Signature.Hash[:], order.Hash())
-
-		hashedBytes := append([]byte("\x19Ethereum Signed Message:\n32"), order.Signature.Hash[:]...)
-		signedBytes := crypto.Keccak256(hashedBytes)
+		signOrderWithKey(order, key)
+		utils.WriteRecord(order, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
 
-		sig, _ := crypto.Sign(signedBytes, key)
-		copy(order.Signature.R[:], sig[0:32])
-		copy(order.Signature.S[:], sig[32:64])
-		order.Signature.V = sig[64] + 27
+// SignOrderKeysMain signs each order with the key for its maker address.
+// Orders with no matching key pass through unsigned, unless errOnMismatch is
+// set. A summary of the orders signed by each key is logged.
+func SignOrderKeysMain(inputFile io.Reader, outputFile io.Writer, keys utils.KeyLookup, errOnMismatch bool) subcommands.ExitStatus {
+	signed := make(map[common.Address]int)
+	signers := []common.Address{}
+	unsigned := 0
+	for order := range orderScanner(inputFile) {
+		maker := orCommon.ToGethAddress(order.Maker)
+		key, err := keys.Get(maker)
+		if err != nil {
+			log.Printf("Error signing order %#x: %v", order.Hash(), err.Error())
+			if errOnMismatch {
+				return subcommands.ExitFailure
+			}
+			unsigned++
+			utils.WriteRecord(order, outputFile)
+			continue
+		}
+		signOrderWithKey(order, key)
 		utils.WriteRecord(order, outputFile)
+		if _, ok := signed[maker]; !ok {
+			signers = append(signers, maker)
+		}
+		signed[maker]++
+	}
+	for _, maker := range signers {
+		log.Printf("Signed %v orders with %v", signed[maker], maker.Hex())
+	}
+	if unsigned > 0 {
+		log.Printf("%v orders had no matching key and were not signed", unsigned)
 	}
 	return subcommands.ExitSuccess
 }
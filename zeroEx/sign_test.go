@@ -6,10 +6,16 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/json"
+	"flag"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
 	"github.com/notegio/massive/zeroEx"
 	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -128,3 +134,113 @@ func TestSignOrderErrReplaceDifferent(t *testing.T) {
 		t.Fatalf("Bad exitcode: %v", status)
 	}
 }
+
+func TestSignOrderKeystoreDirectory(t *testing.T) {
+	keyDir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(keyDir)
+	passwordFile := filepath.Join(keyDir, ".password")
+	if err := ioutil.WriteFile(passwordFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	inputBuffer := &bytes.Buffer{}
+	for i := 0; i < 2; i++ {
+		key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		keyJSON, err := keystore.EncryptKey(&keystore.Key{Address: address, PrivateKey: key}, "hunter2", keystore.LightScryptN, keystore.LightScryptP)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := ioutil.WriteFile(filepath.Join(keyDir, address.Hex()), keyJSON, 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+		order := &types.Order{}
+		order.Initialize()
+		copy(order.Maker[:], address[:])
+		orderBytes, err := json.Marshal(order)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		inputBuffer.Write(append(orderBytes, '\n'))
+	}
+	keyOpts := &utils.KeyOptions{}
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	keyOpts.SetFlags(flags)
+	flags.Parse([]string{"--password-file", passwordFile})
+	keys, err := keyOpts.Load(keyDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.SignOrderKeysMain(inputBuffer, outputBuffer, keys, true); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	counter := 0
+	for scanner.Scan() {
+		processedOrder := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), processedOrder); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		if !processedOrder.Signature.Verify(processedOrder.Maker) {
+			t.Errorf("Signature should be valid for maker %v", processedOrder.Maker)
+		}
+		counter++
+	}
+	if counter != 2 {
+		t.Errorf("Expected 2 orders, got %v", counter)
+	}
+}
+
+func TestSignOrderKeyFiles(t *testing.T) {
+	keyDir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(keyDir)
+	keys := utils.NewKeyRing(&utils.KeyOptions{})
+	inputBuffer := &bytes.Buffer{}
+	// The third key is not added to the key ring, so its order should pass
+	// through unsigned
+	for i := 0; i < 3; i++ {
+		key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		if i < 2 {
+			keyFile := filepath.Join(keyDir, address.Hex())
+			if err := crypto.SaveECDSA(keyFile, key); err != nil {
+				t.Fatal(err.Error())
+			}
+			if _, err := keys.AddFile(keyFile); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+		order := &types.Order{}
+		order.Initialize()
+		copy(order.Maker[:], address[:])
+		orderBytes, err := json.Marshal(order)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		inputBuffer.Write(append(orderBytes, '\n'))
+	}
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.SignOrderKeysMain(inputBuffer, outputBuffer, keys, false); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	scanner := bufio.NewScanner(outputBuffer)
+	signed := 0
+	for scanner.Scan() {
+		processedOrder := &types.Order{}
+		if err := json.Unmarshal(scanner.Bytes(), processedOrder); err != nil {
+			t.Fatalf("Error parsing '%v': %v", scanner.Text(), err.Error())
+		}
+		if processedOrder.Signature.Verify(processedOrder.Maker) {
+			signed++
+		}
+	}
+	if signed != 2 {
+		t.Errorf("Expected 2 signed orders, got %v", signed)
+	}
+}
@@ -0,0 +1,156 @@
+package zeroEx
+
+import (
+	"context"
+	"flag"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/exchangecontract"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Order states written by the status command
+const (
+	StateOpen            = "open"
+	StatePartiallyFilled = "partiallyFilled"
+	StateFilled          = "filled"
+	StateCancelled       = "cancelled"
+	StateExpired         = "expired"
+)
+
+// OrderStatusLookup extends funds.FilledLookup with the exchange contract's
+// view of how much of an order's taker token amount can no longer be filled.
+type OrderStatusLookup interface {
+	funds.FilledLookup
+	GetUnavailableAmount(order *types.Order) (*big.Int, error)
+}
+
+type rpcStatusLookup struct {
+	funds.FilledLookup
+	conn *ethclient.Client
+}
+
+func (lookup *rpcStatusLookup) GetUnavailableAmount(order *types.Order) (*big.Int, error) {
+	exchange, err := exchangecontract.NewExchange(orCommon.ToGethAddress(order.ExchangeAddress), lookup.conn)
+	if err != nil {
+		return nil, err
+	}
+	return exchange.GetUnavailableTakerTokenAmount(nil, orderHash(order))
+}
+
+func NewRpcStatusLookup(rpcURL string) (OrderStatusLookup, error) {
+	filledLookup, err := funds.NewRpcFilledLookup(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcStatusLookup{filledLookup, conn}, nil
+}
+
+type status struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+}
+
+func (p *status) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *status) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*status) Name() string     { return "status" }
+func (*status) Synopsis() string { return "Annotate orders with their on-chain fill and cancel state" }
+func (*status) Usage() string {
+	return `msv 0x status [--input FILE] [--output FILE] ETHEREUM_RPC_URL:
+  Look up the filled, cancelled, and unavailable taker token amounts for each
+  order on its exchange contract, and add them to the order along with its
+  state, which is one of open, partiallyFilled, filled, cancelled or expired.
+`
+}
+
+func (p *status) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+}
+
+func (p *status) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	lookup, err := NewRpcStatusLookup(f.Arg(0))
+	if err != nil {
+		log.Printf("Error establishing Ethereum connection: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return StatusMain(p.inputFile, p.outputFile, lookup)
+}
+
+// orderState derives the state of an order from the amounts filled and
+// cancelled, and the exchange's unavailable amount.
+func orderState(order *types.Order, filled, cancelled, unavailable *big.Int) string {
+	takerTokenAmount := new(big.Int).SetBytes(order.TakerTokenAmount[:])
+	if filled.Cmp(takerTokenAmount) >= 0 {
+		return StateFilled
+	}
+	if cancelled.Sign() > 0 && unavailable.Cmp(takerTokenAmount) >= 0 {
+		return StateCancelled
+	}
+	if new(big.Int).SetBytes(order.ExpirationTimestampInSec[:]).Cmp(big.NewInt(time.Now().Unix())) <= 0 {
+		return StateExpired
+	}
+	if unavailable.Cmp(takerTokenAmount) >= 0 {
+		return StateFilled
+	}
+	if filled.Sign() > 0 {
+		return StatePartiallyFilled
+	}
+	return StateOpen
+}
+
+func StatusMain(inputFile io.Reader, outputFile io.Writer, lookup OrderStatusLookup) subcommands.ExitStatus {
+	for order := range orderScanner(inputFile) {
+		filled, err := lookup.GetAmountFilled(order)
+		if err != nil {
+			log.Printf("Error getting filled amount for order %#x: %v", order.Hash(), err.Error())
+			return subcommands.ExitFailure
+		}
+		cancelled, err := lookup.GetAmountCancelled(order)
+		if err != nil {
+			log.Printf("Error getting cancelled amount for order %#x: %v", order.Hash(), err.Error())
+			return subcommands.ExitFailure
+		}
+		unavailable, err := lookup.GetUnavailableAmount(order)
+		if err != nil {
+			log.Printf("Error getting unavailable amount for order %#x: %v", order.Hash(), err.Error())
+			return subcommands.ExitFailure
+		}
+		order.TakerTokenAmountFilled = filled
+		order.TakerTokenAmountCancelled = cancelled
+		filledInt := new(big.Int).SetBytes(filled[:])
+		cancelledInt := new(big.Int).SetBytes(cancelled[:])
+		writeAnnotatedOrder(order, map[string]interface{}{
+			"takerTokenAmountFilled":      filledInt.String(),
+			"takerTokenAmountCancelled":   cancelledInt.String(),
+			"unavailableTakerTokenAmount": unavailable.String(),
+			"state":                       orderState(order, filledInt, cancelledInt, unavailable),
+		}, outputFile)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,72 @@
+package zeroEx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type mockStatusLookup struct {
+	funds.FilledLookup
+	unavailable *big.Int
+}
+
+func (lookup *mockStatusLookup) GetUnavailableAmount(order *types.Order) (*big.Int, error) {
+	return lookup.unavailable, nil
+}
+
+func checkOrderState(t *testing.T, expiration int64, cancelled, filled string, unavailable int64, expectedState string) {
+	order := &types.Order{}
+	order.Initialize()
+	copy(order.TakerTokenAmount[:], abi.U256(big.NewInt(100)))
+	copy(order.ExpirationTimestampInSec[:], abi.U256(big.NewInt(expiration)))
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	lookup := &mockStatusLookup{funds.NewMockFilledLookup(cancelled, filled, nil), big.NewInt(unavailable)}
+	outputBuffer := &bytes.Buffer{}
+	if status := zeroEx.StatusMain(bytes.NewReader(orderBytes), outputBuffer, lookup); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(outputBuffer.Bytes(), &record); err != nil {
+		t.Fatalf("Error parsing '%v': %v", outputBuffer.String(), err.Error())
+	}
+	if record["state"] != expectedState {
+		t.Errorf("Expected state %v, got %v", expectedState, record["state"])
+	}
+	if record["takerTokenAmountFilled"] != filled {
+		t.Errorf("Unexpected takerTokenAmountFilled: %v", record["takerTokenAmountFilled"])
+	}
+	if record["takerTokenAmountCancelled"] != cancelled {
+		t.Errorf("Unexpected takerTokenAmountCancelled: %v", record["takerTokenAmountCancelled"])
+	}
+}
+
+func TestStatusOpen(t *testing.T) {
+	checkOrderState(t, time.Now().Unix()+3600, "0", "0", 0, zeroEx.StateOpen)
+}
+
+func TestStatusPartiallyFilled(t *testing.T) {
+	checkOrderState(t, time.Now().Unix()+3600, "0", "40", 40, zeroEx.StatePartiallyFilled)
+}
+
+func TestStatusFilled(t *testing.T) {
+	checkOrderState(t, time.Now().Unix()-3600, "0", "100", 100, zeroEx.StateFilled)
+}
+
+func TestStatusCancelled(t *testing.T) {
+	checkOrderState(t, time.Now().Unix()+3600, "60", "40", 100, zeroEx.StateCancelled)
+}
+
+func TestStatusExpired(t *testing.T) {
+	checkOrderState(t, time.Now().Unix()-3600, "0", "40", 40, zeroEx.StateExpired)
+}
@@ -0,0 +1,199 @@
+package zeroEx
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+)
+
+type csvWriter struct {
+	inputFileName  string
+	outputFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	tokenFiles     string
+	rpcURL         string
+	hash           bool
+	price          bool
+	decimalAmounts bool
+}
+
+func (p *csvWriter) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *csvWriter) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*csvWriter) Name() string     { return "toCSV" }
+func (*csvWriter) Synopsis() string { return "Write orders to a CSV" }
+func (*csvWriter) Usage() string {
+	return `msv 0x toCSV [--hash] [--price] [--decimal-amounts] [--tokens FILE[,FILE...]] [--rpc ETHEREUM_RPC_URL] [--input FILE] [--output FILE]:
+  Write orders from a stream to a CSV, with the columns "msv 0x csv" reads,
+  so the CSV can be edited and read back into orders.
+
+  Extra columns can be added for review. --hash adds the order hash as
+  orderHash, and --price adds the price in taker tokens per maker token as
+  orderPrice. --decimal-amounts adds makerTokenAmountDecimal and
+  takerTokenAmountDecimal, the amounts in whole tokens, and gives orderPrice
+  in whole tokens too. Token decimals are taken from the registry files given
+  by --tokens, or looked up on-chain if --rpc is provided. "msv 0x csv"
+  ignores the extra columns.
+`
+}
+
+func (p *csvWriter) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.tokenFiles, "tokens", "", "Comma separated token registry files")
+	f.StringVar(&p.rpcURL, "rpc", "", "Ethereum RPC URL for looking up token decimals")
+	f.BoolVar(&p.hash, "hash", false, "Add an orderHash column")
+	f.BoolVar(&p.price, "price", false, "Add an orderPrice column")
+	f.BoolVar(&p.decimalAmounts, "decimal-amounts", false, "Add columns with the token amounts in whole tokens")
+}
+
+func (p *csvWriter) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var tokens *TokenRegistry
+	if p.decimalAmounts {
+		var err error
+		if tokens, err = loadTokenRegistry(p.rpcURL, p.tokenFiles); err != nil {
+			log.Printf("Error loading token registry: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	return ToCSVMain(p.inputFile, p.outputFile, tokens, p.hash, p.price)
+}
+
+// csvColumns are the order columns read by CSVTokensMain, in the order
+// ToCSVMain writes them
+var csvColumns = []string{
+	"exchangeContractAddress",
+	"maker",
+	"taker",
+	"makerTokenAddress",
+	"takerTokenAddress",
+	"feeRecipient",
+	"makerTokenAmount",
+	"takerTokenAmount",
+	"makerFee",
+	"takerFee",
+	"expirationUnixTimestampSec",
+	"salt",
+	"ecSignature.v",
+	"ecSignature.r",
+	"ecSignature.s",
+}
+
+func csvRow(order *types.Order) []string {
+	uint256 := func(value *types.Uint256) string { return new(big.Int).SetBytes(value[:]).String() }
+	return []string{
+		fmt.Sprintf("%#x", order.ExchangeAddress[:]),
+		fmt.Sprintf("%#x", order.Maker[:]),
+		fmt.Sprintf("%#x", order.Taker[:]),
+		fmt.Sprintf("%#x", order.MakerToken[:]),
+		fmt.Sprintf("%#x", order.TakerToken[:]),
+		fmt.Sprintf("%#x", order.FeeRecipient[:]),
+		uint256(order.MakerTokenAmount),
+		uint256(order.TakerTokenAmount),
+		uint256(order.MakerFee),
+		uint256(order.TakerFee),
+		uint256(order.ExpirationTimestampInSec),
+		uint256(order.Salt),
+		fmt.Sprintf("%v", order.Signature.V),
+		fmt.Sprintf("%#x", order.Signature.R[:]),
+		fmt.Sprintf("%#x", order.Signature.S[:]),
+	}
+}
+
+// formatDecimal formats value as a decimal with at most places decimal
+// places, without trailing zeros
+func formatDecimal(value *big.Rat, places int) string {
+	result := value.FloatString(places)
+	if strings.Contains(result, ".") {
+		result = strings.TrimRight(strings.TrimRight(result, "0"), ".")
+	}
+	return result
+}
+
+// tokenDecimals converts an amount in base units to whole tokens
+func tokenDecimals(amount *types.Uint256, token *Token) *big.Rat {
+	value := new(big.Rat).SetInt(new(big.Int).SetBytes(amount[:]))
+	return value.Quo(value, token.scale())
+}
+
+// ToCSVMain writes orders to a CSV that CSVMain can read. If tokens is not
+// nil, decimal amount columns are added.
+func ToCSVMain(inputFile io.Reader, outputFile io.Writer, tokens *TokenRegistry, hash, price bool) subcommands.ExitStatus {
+	writer := csv.NewWriter(outputFile)
+	headers := append([]string{}, csvColumns...)
+	if hash {
+		headers = append(headers, "orderHash")
+	}
+	if price {
+		headers = append(headers, "orderPrice")
+	}
+	if tokens != nil {
+		headers = append(headers, "makerTokenAmountDecimal", "takerTokenAmountDecimal")
+	}
+	if err := writer.Write(headers); err != nil {
+		log.Printf("Error writing CSV: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	for order := range orderScanner(inputFile) {
+		row := csvRow(order)
+		var makerToken, takerToken *Token
+		if tokens != nil {
+			var err error
+			if makerToken, err = tokens.Lookup(order.MakerToken); err != nil {
+				log.Printf("Error looking up maker token for order %#x: %v", order.Hash(), err.Error())
+				return subcommands.ExitFailure
+			}
+			if takerToken, err = tokens.Lookup(order.TakerToken); err != nil {
+				log.Printf("Error looking up taker token for order %#x: %v", order.Hash(), err.Error())
+				return subcommands.ExitFailure
+			}
+		}
+		if hash {
+			row = append(row, fmt.Sprintf("%#x", order.Hash()))
+		}
+		if price {
+			orderPrice := orderPrice(order)
+			if tokens != nil {
+				orderPrice.Mul(orderPrice, makerToken.scale())
+				orderPrice.Quo(orderPrice, takerToken.scale())
+			}
+			row = append(row, formatDecimal(orderPrice, 18))
+		}
+		if tokens != nil {
+			row = append(row,
+				formatDecimal(tokenDecimals(order.MakerTokenAmount, makerToken), int(*makerToken.Decimals)),
+				formatDecimal(tokenDecimals(order.TakerTokenAmount, takerToken), int(*takerToken.Decimals)),
+			)
+		}
+		if err := writer.Write(row); err != nil {
+			log.Printf("Error writing CSV: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Error writing CSV: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,65 @@
+package zeroEx_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"testing"
+)
+
+func TestToCSVRoundTrip(t *testing.T) {
+	csvData := `maker,makerTokenAddress,makerTokenAmount,makerFee,taker,takerTokenAddress,takerTokenAmount,takerFee,expirationUnixTimestampSec,feeRecipient,salt,exchangeContractAddress,ecSignature.v,ecSignature.r,ecSignature.s
+0x324454186bb728a3ea55750e0618ff1b18ce6cf8,0xa1df88ea6a08722055250ed65601872e59cddfaa,1000000000000000000,0,,0xc778417e063141139fce010982780140aa0cd5ab,1500000,0,1502841540,0x0000000000000000000000000000000000000000,11065671350908846865864045738088581419204014210814002044381812654087807531,0x479cc461fecd078f766ecc58533d6f69580cf3ac,27,0x021fe6dba378a347ea5c581adcd0e0e454e9245703d197075f5d037d0935ac2e,0x12ac107cb04be663f542394832bbcb348deda8b5aa393a97a4cc3139501007f1
+0x324454186bb728a3ea55750e0618ff1b18ce6cf8,0xc778417e063141139fce010982780140aa0cd5ab,2000000,5,0x0000000000000000000000000000000000000001,0xa1df88ea6a08722055250ed65601872e59cddfaa,1000000000000000000,7,1502841541,0x0000000000000000000000000000000000000002,12,0x479cc461fecd078f766ecc58533d6f69580cf3ac,0,0x0000000000000000000000000000000000000000000000000000000000000000,0x0000000000000000000000000000000000000000000000000000000000000000
+`
+	ordersBuffer := &bytes.Buffer{}
+	if status := zeroEx.CSVMain(bytes.NewBufferString(csvData), ordersBuffer); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode reading CSV: %v", status)
+	}
+	tokens := zeroEx.NewTokenRegistry(nil)
+	var zrxDecimals, usdcDecimals uint8 = 18, 6
+	if err := tokens.Add(&zeroEx.Token{Symbol: "ZRX", Address: "0xa1df88ea6a08722055250ed65601872e59cddfaa", Decimals: &zrxDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := tokens.Add(&zeroEx.Token{Symbol: "USDC", Address: "0xc778417e063141139fce010982780140aa0cd5ab", Decimals: &usdcDecimals}); err != nil {
+		t.Fatal(err.Error())
+	}
+	csvBuffer := &bytes.Buffer{}
+	if status := zeroEx.ToCSVMain(bytes.NewReader(ordersBuffer.Bytes()), csvBuffer, tokens, true, true); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode writing CSV: %v", status)
+	}
+	records, err := csv.NewReader(bytes.NewReader(csvBuffer.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %v", len(records))
+	}
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		columns[header] = i
+	}
+	for column, expected := range map[string]string{
+		"orderPrice":              "1.5",
+		"makerTokenAmountDecimal": "1",
+		"takerTokenAmountDecimal": "1.5",
+	} {
+		idx, ok := columns[column]
+		if !ok {
+			t.Errorf("Missing column %v", column)
+		} else if records[1][idx] != expected {
+			t.Errorf("Unexpected %v: %v", column, records[1][idx])
+		}
+	}
+	if _, ok := columns["orderHash"]; !ok {
+		t.Errorf("Missing column orderHash")
+	}
+	roundTripBuffer := &bytes.Buffer{}
+	if status := zeroEx.CSVMain(bytes.NewReader(csvBuffer.Bytes()), roundTripBuffer); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode reading CSV back: %v", status)
+	}
+	if !bytes.Equal(ordersBuffer.Bytes(), roundTripBuffer.Bytes()) {
+		t.Errorf("Orders changed in round trip:\n%v\n%v", ordersBuffer.String(), roundTripBuffer.String())
+	}
+}
@@ -0,0 +1,211 @@
+package zeroEx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	orCommon "github.com/notegio/openrelay/common"
+	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// tokenMetadataABI covers the optional ERC20 metadata functions, which the
+// generated token bindings leave out
+const tokenMetadataABI = `[{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"type":"function"},{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"type":"function"}]`
+
+// Token describes an ERC20 token in a token registry file
+type Token struct {
+	Symbol   string `json:"symbol"`
+	Address  string `json:"address"`
+	Decimals *uint8 `json:"decimals"`
+	address  types.Address
+}
+
+// TokenRegistry resolves token symbols to addresses, and tokens to their
+// decimals. Tokens are loaded from registry files, and if an Ethereum
+// connection is available, missing symbols and decimals are looked up
+// on-chain.
+type TokenRegistry struct {
+	conn      bind.ContractCaller
+	bySymbol  map[string]*Token
+	byAddress map[types.Address]*Token
+	mutex     sync.Mutex
+}
+
+// NewTokenRegistry creates an empty registry. conn may be nil, in which case
+// nothing is looked up on-chain.
+func NewTokenRegistry(conn bind.ContractCaller) *TokenRegistry {
+	return &TokenRegistry{
+		conn:      conn,
+		bySymbol:  make(map[string]*Token),
+		byAddress: make(map[types.Address]*Token),
+	}
+}
+
+// loadTokenRegistry creates a registry from comma separated registry files,
+// connecting to rpcURL for on-chain lookups if it is not empty.
+func loadTokenRegistry(rpcURL, tokenFiles string) (*TokenRegistry, error) {
+	var tokens *TokenRegistry
+	if rpcURL != "" {
+		conn, err := ethclient.Dial(rpcURL)
+		if err != nil {
+			return nil, err
+		}
+		tokens = NewTokenRegistry(conn)
+	} else {
+		tokens = NewTokenRegistry(nil)
+	}
+	if tokenFiles != "" {
+		for _, fileName := range strings.Split(tokenFiles, ",") {
+			if err := tokens.LoadFile(fileName); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// LoadFile adds the tokens in a JSON registry file, which holds a list of
+// objects with "symbol", "address" and "decimals" fields.
+func (registry *TokenRegistry) LoadFile(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	tokens := []*Token{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("parsing %v: %v", fileName, err.Error())
+	}
+	for _, token := range tokens {
+		if err := registry.Add(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add adds a token to the registry, looking up its symbol and decimals
+// on-chain if they were not provided.
+func (registry *TokenRegistry) Add(token *Token) error {
+	if !common.IsHexAddress(token.Address) {
+		return fmt.Errorf("invalid address for token %v: '%v'", token.Symbol, token.Address)
+	}
+	copy(token.address[:], common.HexToAddress(token.Address).Bytes())
+	if token.Symbol == "" || token.Decimals == nil {
+		if err := registry.lookupMetadata(token); err != nil {
+			return fmt.Errorf("looking up token %v: %v", token.Address, err.Error())
+		}
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if token.Symbol != "" {
+		registry.bySymbol[strings.ToUpper(token.Symbol)] = token
+	}
+	registry.byAddress[token.address] = token
+	return nil
+}
+
+func (registry *TokenRegistry) lookupMetadata(token *Token) error {
+	if registry.conn == nil {
+		return errors.New("symbol and decimals are required without an Ethereum connection")
+	}
+	parsed, err := abi.JSON(strings.NewReader(tokenMetadataABI))
+	if err != nil {
+		return err
+	}
+	contract := bind.NewBoundContract(orCommon.ToGethAddress(&token.address), parsed, registry.conn, nil)
+	if token.Decimals == nil {
+		decimals := new(uint8)
+		if err := contract.Call(nil, decimals, "decimals"); err != nil {
+			return err
+		}
+		token.Decimals = decimals
+	}
+	if token.Symbol == "" {
+		// Some older tokens return their symbol as bytes32, which we can't
+		// unpack as a string. Those tokens can still be used by address.
+		symbol := new(string)
+		if err := contract.Call(nil, symbol, "symbol"); err == nil {
+			token.Symbol = *symbol
+		}
+	}
+	return nil
+}
+
+// Symbol returns the token with the given symbol, ignoring case
+func (registry *TokenRegistry) Symbol(symbol string) (*Token, error) {
+	registry.mutex.Lock()
+	token, ok := registry.bySymbol[strings.ToUpper(symbol)]
+	registry.mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown token symbol: %v", symbol)
+	}
+	return token, nil
+}
+
+// Lookup returns the token at an address, looking it up on-chain if it is
+// not in the registry.
+func (registry *TokenRegistry) Lookup(address *types.Address) (*Token, error) {
+	registry.mutex.Lock()
+	token, ok := registry.byAddress[*address]
+	registry.mutex.Unlock()
+	if ok {
+		return token, nil
+	}
+	token = &Token{Address: address.String()}
+	if err := registry.Add(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Resolve returns the token for a symbol or hex address
+func (registry *TokenRegistry) Resolve(value string) (*Token, error) {
+	if !strings.HasPrefix(value, "0x") {
+		return registry.Symbol(value)
+	}
+	if !common.IsHexAddress(value) {
+		return nil, fmt.Errorf("invalid token address: %v", value)
+	}
+	address := &types.Address{}
+	copy(address[:], common.HexToAddress(value).Bytes())
+	return registry.Lookup(address)
+}
+
+// BaseUnits converts a decimal amount of the token, such as "1.5", to base
+// units. Amounts with more precision than the token's decimals are rejected.
+func (token *Token) BaseUnits(amount string) (*big.Int, error) {
+	value, err := parseDecimal(amount)
+	if err != nil {
+		return nil, err
+	}
+	value.Mul(value, token.scale())
+	if !value.IsInt() {
+		return nil, fmt.Errorf("%v has more than the %v decimal places %v allows", amount, *token.Decimals, token.Symbol)
+	}
+	return value.Num(), nil
+}
+
+// scale returns the number of base units in one token
+func (token *Token) scale() *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*token.Decimals)), nil))
+}
+
+// parseDecimal parses a non-negative decimal number such as "1.5"
+func parseDecimal(amount string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok || strings.ContainsAny(amount, "/eE") {
+		return nil, fmt.Errorf("invalid amount: %v", amount)
+	}
+	if value.Sign() < 0 {
+		return nil, fmt.Errorf("negative amount: %v", amount)
+	}
+	return value, nil
+}
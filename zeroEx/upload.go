@@ -8,20 +8,29 @@ import (
 	"fmt"
 	"github.com/google/subcommands"
 	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/types"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type upload struct {
 	targetURL      string
 	inputFileName  string
 	outputFileName string
+	failedFileName string
 	inputFile      *os.File
 	outputFile     *os.File
+	concurrency    int
+	rate           float64
+	retries        int
+	timeout        time.Duration
 }
 
 func (p *upload) FileNames() (string, string) {
@@ -33,10 +42,19 @@ func (p *upload) SetIOFiles(inputFile, outputFile *os.File) {
 }
 
 func (*upload) Name() string     { return "upload" }
-func (*upload) Synopsis() string { return "Set fees on incoming orders" }
+func (*upload) Synopsis() string { return "Upload orders to a relayer" }
 func (*upload) Usage() string {
-	return `msv 0x upload [--target RELAYER_URL] [--input FILE] [--output FILE]:
-  Upload orders to the target relayer
+	return `msv 0x upload [--target RELAYER_URL] [--concurrency N] [--rate N] [--retries N] [--timeout DURATION] [--failed FILE] [--input FILE] [--output FILE]:
+  Upload orders to the target relayer. Each order is written to the output
+  with its hash, the HTTP status the relayer returned, and the response body
+  as the error if the order was not accepted. Orders are written in the order
+  their uploads finish, which may differ from the input with --concurrency.
+
+  Uploads that fail with a network error, a 5xx status or a 429 status are
+  retried up to --retries times, waiting twice as long before each retry.
+  --rate limits the number of requests per second across all concurrent
+  uploads, including retries. Orders the relayer did not accept are also
+  written to the --failed file, so they can be fixed and uploaded again.
 `
 }
 
@@ -44,39 +62,219 @@ func (p *upload) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&p.targetURL, "target", "https://api.openrelay.xyz", "Set the target 0x relayer")
 	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
 	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.failedFileName, "failed", "", "File for orders the relayer did not accept")
+	f.IntVar(&p.concurrency, "concurrency", 1, "The number of orders to upload at once")
+	f.Float64Var(&p.rate, "rate", 0, "The most requests to make per second [unlimited]")
+	f.IntVar(&p.retries, "retries", 3, "The number of times to retry an upload that failed with a network error, 5xx or 429 status")
+	f.DurationVar(&p.timeout, "timeout", 30*time.Second, "The timeout for each request")
 }
 
 func (p *upload) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	if f.NArg() != 0 {
+	if f.NArg() != 0 || p.concurrency < 1 || p.retries < 0 || p.rate < 0 {
 		os.Stderr.WriteString(p.Usage())
 		return subcommands.ExitUsageError
 	}
 	utils.SetIO(p)
-	return UploadMain(p.targetURL, p.inputFile, p.outputFile)
-}
-
-func UploadMain(targetURL string, inputFile io.Reader, outputFile io.Writer) subcommands.ExitStatus {
-	targetURL = strings.TrimSuffix(targetURL, "/")
-	counter := 0
-	for order := range orderScanner(inputFile) {
-		counter++
-		data, err := json.Marshal(order)
+	var failedFile io.Writer = ioutil.Discard
+	if p.failedFileName != "" {
+		file, err := utils.OpenOutput(p.failedFileName)
 		if err != nil {
-			log.Printf("Error serializing order: %v", err.Error())
+			log.Printf("Error opening failed file: %v", err.Error())
 			return subcommands.ExitFailure
 		}
-		resp, err := http.Post(fmt.Sprintf("%v/v0/order", targetURL), "application/json", bytes.NewReader(data))
+		defer file.Close()
+		failedFile = file
+	}
+	uploader := NewUploader(p.targetURL, p.timeout, p.retries, p.rate)
+	return UploadMain(uploader, p.concurrency, p.inputFile, p.outputFile, failedFile)
+}
+
+// Uploader posts orders to a relayer, retrying transient failures and
+// limiting the rate of requests. It is safe for concurrent use.
+type Uploader struct {
+	TargetURL string
+	Client    *http.Client
+	Retries   int
+	// Backoff is how long to wait before the first retry. It doubles for
+	// each retry after that.
+	Backoff  time.Duration
+	interval time.Duration
+	next     time.Time
+	mutex    sync.Mutex
+}
+
+// NewUploader creates an Uploader for the relayer at targetURL. A rate of zero
+// means requests are not limited.
+func NewUploader(targetURL string, timeout time.Duration, retries int, rate float64) *Uploader {
+	uploader := &Uploader{
+		TargetURL: strings.TrimSuffix(targetURL, "/"),
+		Client:    &http.Client{Timeout: timeout},
+		Retries:   retries,
+		Backoff:   time.Second,
+	}
+	if rate > 0 {
+		uploader.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return uploader
+}
+
+// wait blocks until the rate limit allows another request
+func (uploader *Uploader) wait() {
+	if uploader.interval == 0 {
+		return
+	}
+	uploader.mutex.Lock()
+	now := time.Now()
+	if uploader.next.Before(now) {
+		uploader.next = now
+	}
+	delay := uploader.next.Sub(now)
+	uploader.next = uploader.next.Add(uploader.interval)
+	uploader.mutex.Unlock()
+	time.Sleep(delay)
+}
+
+// uploadResult is the outcome of uploading a single order
+type uploadResult struct {
+	status   int
+	err      string
+	attempts int
+}
+
+func (result *uploadResult) ok() bool {
+	return result.status == 200 || result.status == 202
+}
+
+func (result *uploadResult) annotations(order *types.Order) map[string]interface{} {
+	annotations := map[string]interface{}{
+		"hash":   fmt.Sprintf("%#x", order.Hash()),
+		"status": result.status,
+	}
+	if result.err != "" {
+		annotations["error"] = result.err
+	}
+	return annotations
+}
+
+// retryable reports whether an upload that failed with status (zero for a
+// network error) should be retried
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// post makes a single attempt to upload data, returning the status and body.
+// If the relayer asked us to wait with a Retry-After header, that is returned
+// as well.
+func (uploader *Uploader) post(data []byte) (int, string, time.Duration, error) {
+	uploader.wait()
+	resp, err := uploader.Client.Post(fmt.Sprintf("%v/v0/order", uploader.TargetURL), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return resp.StatusCode, string(body), retryAfter, nil
+}
+
+// Upload posts an order, retrying network errors, 5xx and 429 responses
+func (uploader *Uploader) Upload(order *types.Order) *uploadResult {
+	result := &uploadResult{}
+	data, err := json.Marshal(order)
+	if err != nil {
+		result.err = err.Error()
+		return result
+	}
+	backoff := uploader.Backoff
+	for {
+		result.attempts++
+		status, body, retryAfter, err := uploader.post(data)
+		result.status = status
 		if err != nil {
-			log.Printf("Error uploading order to %v: %v", targetURL, err.Error())
-			return subcommands.ExitFailure
+			result.err = err.Error()
+		} else if !result.ok() {
+			result.err = body
+		} else {
+			result.err = ""
 		}
-		if resp.StatusCode != 202 && resp.StatusCode != 200 {
-			log.Printf("Got unexpected status code: %v", resp.StatusCode)
-			body, _ := ioutil.ReadAll(resp.Body)
-			log.Printf("Body: %v", string(body))
+		if result.ok() || !retryable(status) || result.attempts > uploader.Retries {
+			return result
+		}
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		time.Sleep(delay)
+		backoff *= 2
+	}
+}
+
+// UploadMain uploads orders with up to concurrency uploads at once, writing a
+// result record for each order to outputFile and the orders that were not
+// accepted to failedFile
+func UploadMain(uploader *Uploader, concurrency int, inputFile io.Reader, outputFile, failedFile io.Writer) subcommands.ExitStatus {
+	type orderResult struct {
+		order  *types.Order
+		result *uploadResult
+	}
+	orders := orderScanner(inputFile)
+	results := make(chan orderResult)
+	// done is closed if results can't be written, so the workers stop
+	// uploading and the rest of the input is read without being used
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for order := range orders {
+				select {
+				case <-done:
+					continue
+				default:
+				}
+				select {
+				case results <- orderResult{order, uploader.Upload(order)}:
+				case <-done:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	uploaded, failed := 0, 0
+	for item := range results {
+		var err error
+		if item.result.ok() {
+			uploaded++
+		} else {
+			failed++
+			log.Printf("Error uploading order %#x after %v attempts: status %v: %v", item.order.Hash(), item.result.attempts, item.result.status, item.result.err)
+			err = utils.WriteRecord(item.order, failedFile)
+		}
+		if err == nil {
+			err = writeAnnotatedOrder(item.order, item.result.annotations(item.order), outputFile)
+		}
+		if err != nil {
+			log.Printf("Error writing result: %v", err.Error())
+			close(done)
+			for _ = range results {
+			}
 			return subcommands.ExitFailure
 		}
 	}
-	log.Printf("Successfully uploaded %v orders to %v", counter, targetURL)
+	log.Printf("Successfully uploaded %v orders to %v", uploaded, uploader.TargetURL)
+	if failed > 0 {
+		log.Printf("Failed to upload %v orders", failed)
+		return subcommands.ExitFailure
+	}
 	return subcommands.ExitSuccess
 }
@@ -0,0 +1,133 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUploadRetriesAndFailures(t *testing.T) {
+	// Salt 1 fails once before it is accepted, salt 2 is always rejected, and
+	// salt 3 is accepted
+	attempts := make(map[byte]int)
+	mutex := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		order := &types.Order{}
+		if err := json.Unmarshal(body, order); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		salt := order.Salt[31]
+		mutex.Lock()
+		attempts[salt]++
+		count := attempts[salt]
+		mutex.Unlock()
+		switch {
+		case salt == 1 && count == 1:
+			w.WriteHeader(503)
+		case salt == 2:
+			w.WriteHeader(400)
+			w.Write([]byte(`{"code":100,"reason":"Validation Failed"}`))
+		default:
+			w.WriteHeader(202)
+		}
+	}))
+	defer server.Close()
+	inputBuffer := &bytes.Buffer{}
+	for salt := byte(1); salt <= 3; salt++ {
+		order := &types.Order{}
+		order.Initialize()
+		order.Salt[31] = salt
+		data, _ := json.Marshal(order)
+		inputBuffer.Write(append(data, '\n'))
+	}
+	uploader := zeroEx.NewUploader(server.URL+"/", time.Second, 2, 0)
+	uploader.Backoff = time.Millisecond
+	outputBuffer := &bytes.Buffer{}
+	failedBuffer := &bytes.Buffer{}
+	if status := zeroEx.UploadMain(uploader, 2, inputBuffer, outputBuffer, failedBuffer); status != subcommands.ExitFailure {
+		t.Errorf("Expected failure exitcode, got %v", status)
+	}
+	statuses := make(map[byte]float64)
+	scanner := bufio.NewScanner(outputBuffer)
+	for scanner.Scan() {
+		order := &types.Order{}
+		record := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), order); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err.Error())
+		}
+		if record["hash"] == "" {
+			t.Errorf("Missing hash for salt %v", order.Salt[31])
+		}
+		statuses[order.Salt[31]] = record["status"].(float64)
+		if order.Salt[31] == 2 && record["error"] != `{"code":100,"reason":"Validation Failed"}` {
+			t.Errorf("Unexpected error: %v", record["error"])
+		}
+	}
+	if statuses[1] != 202 || statuses[2] != 400 || statuses[3] != 202 || len(statuses) != 3 {
+		t.Errorf("Unexpected statuses: %v", statuses)
+	}
+	if attempts[1] != 2 || attempts[2] != 1 {
+		t.Errorf("Unexpected attempts: %v", attempts)
+	}
+	failed := &types.Order{}
+	if err := json.Unmarshal(failedBuffer.Bytes(), failed); err != nil {
+		t.Fatalf("Error parsing failed orders '%v': %v", failedBuffer.String(), err.Error())
+	}
+	if failed.Salt[31] != 2 {
+		t.Errorf("Unexpected failed order with salt %v", failed.Salt[31])
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestUploadWriteError(t *testing.T) {
+	var uploads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&uploads, 1)
+		w.WriteHeader(202)
+	}))
+	defer server.Close()
+	inputBuffer := &bytes.Buffer{}
+	for i := 0; i < 50; i++ {
+		order := &types.Order{}
+		order.Initialize()
+		order.Salt[31] = byte(i)
+		orderBytes, _ := json.Marshal(order)
+		inputBuffer.Write(append(orderBytes, '\n'))
+	}
+	uploader := zeroEx.NewUploader(server.URL, time.Second, 0, 0)
+	if status := zeroEx.UploadMain(uploader, 2, inputBuffer, failingWriter{}, ioutil.Discard); status != subcommands.ExitFailure {
+		t.Fatalf("Expected a write error to fail, got %v", status)
+	}
+	// Uploading stops once the output can't be written, and every worker
+	// has finished by the time UploadMain returns
+	count := atomic.LoadInt32(&uploads)
+	if count >= 50 {
+		t.Errorf("Expected uploads to stop after the write error, got %v", count)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after := atomic.LoadInt32(&uploads); after != count {
+		t.Errorf("Expected no uploads after UploadMain returned, got %v more", after-count)
+	}
+}
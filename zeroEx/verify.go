@@ -0,0 +1,133 @@
+package zeroEx
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/utils"
+	"github.com/notegio/openrelay/config"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"time"
+)
+
+type verify struct {
+	inputFileName  string
+	outputFileName string
+	rejectFileName string
+	inputFile      *os.File
+	outputFile     *os.File
+	strict         bool
+}
+
+func (p *verify) FileNames() (string, string) {
+	return p.inputFileName, p.outputFileName
+}
+
+func (p *verify) SetIOFiles(inputFile, outputFile *os.File) {
+	p.inputFile, p.outputFile = inputFile, outputFile
+}
+
+func (*verify) Name() string     { return "verify" }
+func (*verify) Synopsis() string { return "Verify that orders are signed, unexpired, and fillable" }
+func (*verify) Usage() string {
+	return `msv 0x verify [--strict] [--reject FILE] [--input FILE] [--output FILE] ETHEREUM_RPC_URL:
+  Check that each order has a valid signature from its maker, has not expired,
+  and that the maker has the balances and allowances needed to fill it. Valid
+  orders are written to the output. Invalid orders are written to the reject
+  file along with the reason they were rejected, or dropped if no reject file
+  is specified. If --strict is provided, the first invalid order will cause
+  the command to fail.
+`
+}
+
+func (p *verify) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.inputFileName, "input", "", "Input file [stdin]")
+	f.StringVar(&p.outputFileName, "output", "", "Output file [stdout]")
+	f.StringVar(&p.rejectFileName, "reject", "", "File for orders that fail verification")
+	f.BoolVar(&p.strict, "strict", false, "Exit with a non-zero exit code if any order fails verification")
+}
+
+func (p *verify) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		os.Stderr.WriteString(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	utils.SetIO(p)
+	var rejectFile io.Writer = ioutil.Discard
+	if p.rejectFileName != "" {
+		file, err := utils.OpenOutput(p.rejectFileName)
+		if err != nil {
+			log.Printf("Error opening reject file: %v", err.Error())
+			return subcommands.ExitFailure
+		}
+		defer file.Close()
+		rejectFile = file
+	}
+	tokenProxyCfg, err := config.NewRpcTokenProxy(f.Arg(0))
+	if err != nil {
+		log.Printf("Error setting up TokenProxy config: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	feeTokenCfg, err := config.NewRpcFeeToken(f.Arg(0))
+	if err != nil {
+		log.Printf("Error setting up FeeToken config: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	validator, err := funds.NewRpcOrderValidator(f.Arg(0), feeTokenCfg, tokenProxyCfg)
+	if err != nil {
+		log.Printf("Error initializing order validator: %v", err.Error())
+		return subcommands.ExitFailure
+	}
+	return VerifyMain(p.inputFile, p.outputFile, rejectFile, validator, p.strict)
+}
+
+// verifyOrder returns a description of why the order is invalid, or an empty
+// string if the order passed every check.
+func verifyOrder(order *types.Order, validator funds.OrderValidator) string {
+	if !bytes.Equal(order.Signature.Hash[:], order.Hash()) {
+		return "signature hash does not match order hash"
+	}
+	if !order.Signature.Verify(order.Maker) {
+		return "signature does not match maker"
+	}
+	expiration := new(big.Int).SetBytes(order.ExpirationTimestampInSec[:])
+	if expiration.Cmp(big.NewInt(time.Now().Unix())) <= 0 {
+		return "order has expired"
+	}
+	fillable, err := validator.ValidateOrder(order)
+	if err != nil {
+		return "error validating order: " + err.Error()
+	}
+	if !fillable {
+		return "maker has insufficient funds or allowance"
+	}
+	return ""
+}
+
+func VerifyMain(inputFile io.Reader, outputFile, rejectFile io.Writer, validator funds.OrderValidator, strict bool) subcommands.ExitStatus {
+	rejected := 0
+	for order := range orderScanner(inputFile) {
+		reason := verifyOrder(order, validator)
+		if reason == "" {
+			utils.WriteRecord(order, outputFile)
+			continue
+		}
+		if strict {
+			log.Printf("Order %#x failed verification: %v", order.Hash(), reason)
+			return subcommands.ExitFailure
+		}
+		rejected++
+		writeAnnotatedOrder(order, map[string]interface{}{"reason": reason}, rejectFile)
+	}
+	if rejected > 0 {
+		log.Printf("Rejected %v orders", rejected)
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,99 @@
+package zeroEx_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/subcommands"
+	"github.com/notegio/massive/zeroEx"
+	"github.com/notegio/openrelay/config"
+	"github.com/notegio/openrelay/funds"
+	"github.com/notegio/openrelay/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func verifyTestOrder(t *testing.T, expiration int64) ([]byte, funds.OrderValidator) {
+	order := &types.Order{}
+	order.Initialize()
+	key, _ := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	copy(order.Maker[:], address[:])
+	order.MakerToken[0] = 1
+	copy(order.MakerTokenAmount[:], abi.U256(big.NewInt(100)))
+	copy(order.TakerTokenAmount[:], abi.U256(big.NewInt(100)))
+	copy(order.ExpirationTimestampInSec[:], abi.U256(big.NewInt(expiration)))
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	signedBuffer := &bytes.Buffer{}
+	if status := zeroEx.SignOrderMain(bytes.NewReader(orderBytes), signedBuffer, key, true, false); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode signing order: %v", status)
+	}
+	feeToken := &types.Address{}
+	feeToken[0] = 2
+	balances := map[types.Address]map[types.Address]*big.Int{
+		*order.MakerToken: map[types.Address]*big.Int{*order.Maker: big.NewInt(100)},
+		*feeToken:         map[types.Address]*big.Int{*order.Maker: big.NewInt(0)},
+	}
+	validator := funds.NewOrderValidator(
+		funds.NewMockBalanceChecker(balances),
+		config.StaticFeeToken(feeToken),
+		config.StaticTokenProxy(&types.Address{}),
+	)
+	return signedBuffer.Bytes(), validator
+}
+
+func TestVerifyValid(t *testing.T) {
+	orderBytes, validator := verifyTestOrder(t, time.Now().Unix()+3600)
+	inputFile := bytes.NewReader(orderBytes)
+	outputBuffer := &bytes.Buffer{}
+	outputFile := bufio.NewWriter(outputBuffer)
+	rejectBuffer := &bytes.Buffer{}
+	if status := zeroEx.VerifyMain(inputFile, outputFile, rejectBuffer, validator, true); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	outputFile.Flush()
+	processedOrder := &types.Order{}
+	err := json.Unmarshal(outputBuffer.Bytes(), processedOrder)
+	if err != nil {
+		t.Fatalf("Error parsing '%v': %v", string(outputBuffer.Bytes()), err.Error())
+	}
+	if rejectBuffer.Len() != 0 {
+		t.Errorf("Unexpected rejection: %v", rejectBuffer.String())
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	orderBytes, validator := verifyTestOrder(t, time.Now().Unix()-3600)
+	inputFile := bytes.NewReader(orderBytes)
+	outputBuffer := &bytes.Buffer{}
+	rejectBuffer := &bytes.Buffer{}
+	if status := zeroEx.VerifyMain(inputFile, outputBuffer, rejectBuffer, validator, false); status != subcommands.ExitSuccess {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+	if outputBuffer.Len() != 0 {
+		t.Errorf("Expired order should not be written to output: %v", outputBuffer.String())
+	}
+	rejection := make(map[string]interface{})
+	if err := json.Unmarshal(rejectBuffer.Bytes(), &rejection); err != nil {
+		t.Fatalf("Error parsing '%v': %v", rejectBuffer.String(), err.Error())
+	}
+	if rejection["reason"] != "order has expired" {
+		t.Errorf("Unexpected reason: %v", rejection["reason"])
+	}
+}
+
+func TestVerifyStrict(t *testing.T) {
+	orderBytes, validator := verifyTestOrder(t, time.Now().Unix()-3600)
+	inputFile := bytes.NewReader(orderBytes)
+	if status := zeroEx.VerifyMain(inputFile, &bytes.Buffer{}, &bytes.Buffer{}, validator, true); status != subcommands.ExitFailure {
+		t.Fatalf("Bad exitcode: %v", status)
+	}
+}
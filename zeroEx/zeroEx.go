@@ -28,9 +28,22 @@ func (p *ZeroExCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 	commander.Register(&expiration{}, "")
 	commander.Register(&signOrder{}, "")
 	commander.Register(&upload{}, "")
+	commander.Register(&broadcast{}, "")
 	commander.Register(&csvReader{}, "")
+	commander.Register(&csvWriter{}, "")
 	commander.Register(&setExchange{}, "")
+	commander.Register(&set{}, "")
 	commander.Register(&setAllowance{}, "")
+	commander.Register(&verify{}, "")
+	commander.Register(&filter{}, "")
+	commander.Register(&fill{}, "")
+	commander.Register(&cancel{}, "")
+	commander.Register(&marketFill{}, "")
+	commander.Register(&status{}, "")
+	commander.Register(&orders{}, "")
+	commander.Register(&orderbook{}, "")
+	commander.Register(&ladder{}, "")
+	commander.Register(&dutchAuction{}, "")
 	commander.Register(commander.HelpCommand(), "")
 	commander.Register(commander.FlagsCommand(), "")
 	commander.Register(commander.CommandsCommand(), "")